@@ -0,0 +1,86 @@
+// Command patrun-gen compiles a rule set into a Go function with the same results as patrun's Find.
+//
+// Usage:
+//
+//  patrun-gen [flags] rules-file
+//
+// The rules file is either a rule file for the rules package (.yaml or .yml)
+// or the JSON written by ToJSON (.json). Rule file data is always a string,
+// JSON data may be strings, numbers or bools to match the -type flag.
+//
+// It is intended for use with go generate, eg:
+//
+//  //go:generate go run github.com/colmharte/patrun-golang/cmd/patrun-gen -pkg tax -o tax_match.go tax.yaml
+package main
+
+import (
+  "flag"
+  "fmt"
+  "os"
+  "path/filepath"
+  "strings"
+
+  "github.com/colmharte/patrun-golang/patrun"
+  "github.com/colmharte/patrun-golang/patrun/rules"
+)
+
+func main() {
+  var opts patrun.GenerateOptions
+  var out string
+
+  flag.StringVar(&opts.Package, "pkg", os.Getenv("GOPACKAGE"), "package name of the generated file, defaults to $GOPACKAGE")
+  flag.StringVar(&opts.Func, "func", "Match", "name of the generated function")
+  flag.StringVar(&opts.Type, "type", "string", "Go type of the data")
+  flag.StringVar(&out, "o", "", "output file, defaults to stdout")
+  flag.Usage = func() {
+    fmt.Fprintf(flag.CommandLine.Output(), "usage: patrun-gen [flags] rules-file\n")
+    flag.PrintDefaults()
+  }
+  flag.Parse()
+
+  if flag.NArg() != 1 {
+    flag.Usage()
+    os.Exit(2)
+  }
+
+  if err := generate(flag.Arg(0), out, opts); err != nil {
+    fmt.Fprintf(os.Stderr, "patrun-gen: %v\n", err)
+    os.Exit(1)
+  }
+}
+
+func generate(path string, out string, opts patrun.GenerateOptions) error {
+  pm, err := load(path)
+  if err != nil {
+    return err
+  }
+
+  src, err := pm.GenerateGo(opts)
+  if err != nil {
+    return err
+  }
+
+  if out == "" {
+    _, err = os.Stdout.Write(src)
+    return err
+  }
+
+  return os.WriteFile(out, src, 0666)
+}
+
+func load(path string) (*patrun.Patrun, error) {
+  if strings.EqualFold(filepath.Ext(path), ".json") {
+    data, err := os.ReadFile(path)
+    if err != nil {
+      return nil, err
+    }
+    return patrun.FromJSON(data, nil)
+  }
+
+  rs, err := rules.Load(path)
+  if err != nil {
+    return nil, err
+  }
+
+  return rs.Build(nil)
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+  "bufio"
+  "fmt"
+  "io"
+  "strings"
+  "unicode/utf8"
+)
+
+//a minimal line editor for raw mode terminals with history and tab completion
+type lineEditor struct {
+  in *bufio.Reader
+  out io.Writer
+  prompt string
+  history *[]string
+  //return the word being completed and the possible replacements for it
+  complete func(line string) (string, []string)
+}
+
+//read a line, returning io.EOF when ctrl-d is pressed on an empty line
+func (e *lineEditor) readLine() (string, error) {
+  var buf []byte
+  var recall = len(*e.history)
+
+  redraw := func() {
+    fmt.Fprintf(e.out, "\r\033[K%v%s", e.prompt, buf)
+  }
+
+  redraw()
+
+  for {
+    b, err := e.in.ReadByte()
+    if err != nil {
+      return "", err
+    }
+
+    switch b {
+    case '\r', '\n':
+      fmt.Fprint(e.out, "\r\n")
+      return string(buf), nil
+
+    case 3: // ctrl-c abandons the line
+      fmt.Fprint(e.out, "^C\r\n")
+      buf = buf[:0]
+      recall = len(*e.history)
+      redraw()
+
+    case 4: // ctrl-d
+      if len(buf) == 0 {
+        fmt.Fprint(e.out, "\r\n")
+        return "", io.EOF
+      }
+
+    case 127, 8:
+      if len(buf) > 0 {
+        _, size := utf8.DecodeLastRune(buf)
+        buf = buf[:len(buf) - size]
+        redraw()
+      }
+
+    case '\t':
+      buf = []byte(e.tab(string(buf)))
+      redraw()
+
+    case 27: // escape sequences, only the up and down arrows are used
+      if next, _ := e.in.ReadByte(); next != '[' {
+        continue
+      }
+      key, _ := e.in.ReadByte()
+
+      switch {
+      case key == 'A' && recall > 0:
+        recall--
+        buf = []byte((*e.history)[recall])
+      case key == 'B' && recall < len(*e.history):
+        recall++
+        buf = buf[:0]
+        if recall < len(*e.history) {
+          buf = []byte((*e.history)[recall])
+        }
+      }
+      redraw()
+
+    default:
+      if b >= 32 {
+        buf = append(buf, b)
+        redraw()
+      }
+    }
+  }
+}
+
+//complete the word at the end of the line, listing the options when there is more than one
+func (e *lineEditor) tab(line string) string {
+  word, options := e.complete(line)
+
+  if len(options) == 0 {
+    return line
+  }
+
+  if len(options) > 1 {
+    fmt.Fprintf(e.out, "\r\n%v\r\n", strings.Join(options, "  "))
+  }
+
+  return line[:len(line) - len(word)] + commonPrefix(options)
+}
+
+func commonPrefix(options []string) string {
+  prefix := options[0]
+
+  for _, option := range options[1:] {
+    for !strings.HasPrefix(option, prefix) {
+      prefix = prefix[:len(prefix) - 1]
+    }
+  }
+
+  return prefix
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+  "bytes"
+  "encoding/json"
+  "fmt"
+  "io"
+  "os"
+  "sort"
+  "strings"
+
+  "github.com/colmharte/patrun-golang/patrun"
+  "github.com/colmharte/patrun-golang/patrun/rules"
+)
+
+//load a matcher from a rule file, ToJSON output, or stdin when the path is -
+func load(path string, stdin io.Reader) (*patrun.Patrun, error) {
+  var src []byte
+  var err error
+
+  if path == "-" {
+    src, err = io.ReadAll(stdin)
+    path = "stdin"
+  } else {
+    src, err = os.ReadFile(path)
+  }
+  if err != nil {
+    return nil, err
+  }
+
+  //ToJSON output is always a list, rule files are always a map
+  if isJSON(src) {
+    pm, err := patrun.FromJSON(src, nil)
+    if err != nil {
+      return nil, fmt.Errorf("%v: %w", path, err)
+    }
+    return pm, nil
+  }
+
+  rs, err := rules.Parse(path, src)
+  if err != nil {
+    return nil, err
+  }
+
+  return rs.Build(nil)
+}
+
+func isJSON(src []byte) bool {
+  return bytes.HasPrefix(bytes.TrimSpace(src), []byte("["))
+}
+
+//parse a subject given either in string notation or as a JSON object
+func parseSubject(arg string) (map[string]string, error) {
+  if !strings.HasPrefix(strings.TrimSpace(arg), "{") {
+    return patrun.ParsePattern(arg)
+  }
+
+  var items map[string]interface{}
+  if err := json.Unmarshal([]byte(arg), &items); err != nil {
+    return nil, fmt.Errorf("invalid subject: %w", err)
+  }
+
+  var subject = map[string]string{}
+  for k, v := range items {
+    if s, ok := v.(string); ok {
+      subject[k] = s
+    } else {
+      b, _ := json.Marshal(v)
+      subject[k] = string(b)
+    }
+  }
+
+  return subject, nil
+}
+
+//format data for output, strings are written as they are and everything else as JSON
+func formatData(data interface{}) string {
+  if s, ok := data.(string); ok {
+    return s
+  }
+
+  b, err := json.Marshal(data)
+  if err != nil {
+    return fmt.Sprintf("%v", data)
+  }
+
+  return string(b)
+}
+
+func formatMatch(pat map[string]string) string {
+  var keys []string
+  for k := range pat {
+    keys = append(keys, k)
+  }
+  sort.Strings(keys)
+
+  var points []string
+  for _, key := range keys {
+    points = append(points, fmt.Sprintf("%v:%v", key, pat[key]))
+  }
+
+  return strings.Join(points, ", ")
+}
+
+//convert a matcher to a rule set, data that isn't a string is stored as JSON
+func toRules(pm *patrun.Patrun) (*rules.RuleSet, error) {
+  return rules.FromPatrun(pm, func(data interface{}) (string, error) {
+    if s, ok := data.(string); ok {
+      return s, nil
+    }
+
+    b, err := json.Marshal(data)
+    return string(b), err
+  })
+}
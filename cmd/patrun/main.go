@@ -0,0 +1,338 @@
+// Command patrun answers questions about a rule set from the terminal.
+//
+// Usage:
+//
+//  patrun <command> [flags] [arguments]
+//
+// The commands are:
+//
+//  find        print the data Find returns for a subject
+//  find-exact  print the data FindExact returns for a subject
+//  explain     print the pattern responsible for a subject's result
+//  list        list the patterns matching a query
+//  stats       print statistics about the rule set
+//  export      write the rule set as json, yaml, dot or mermaid
+//  diff        compare two rule sets
+//  lint        report unreachable, shadowed and redundant patterns
+//  repl        explore and edit a rule set interactively
+//
+// Rules are loaded with -rules from a rule file for the rules package or from
+// the JSON written by ToJSON, use - to read from stdin. Subjects and queries
+// are given in string notation, eg "a:1,b:2", or as a JSON object.
+//
+// find, find-exact and explain exit with status 1 when nothing matches, and
+// diff exits with status 1 when the rule sets differ. lint exits with status 1
+// when it reports a finding at or above the -fail severity, warning by default.
+package main
+
+import (
+  "flag"
+  "fmt"
+  "io"
+  "os"
+  "sort"
+
+  "github.com/colmharte/patrun-golang/patrun"
+)
+
+func main() {
+  os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+type command struct {
+  name string
+  usage string
+  run func(c *cli, args []string) int
+}
+
+var commands = []command{
+  {"find", "find -rules file subject", findCommand(false)},
+  {"find-exact", "find-exact -rules file subject", findCommand(true)},
+  {"explain", "explain -rules file subject", explainCommand},
+  {"list", "list -rules file [-exact] [query]", listCommand},
+  {"stats", "stats -rules file", statsCommand},
+  {"export", "export -rules file [-format json|yaml|dot|mermaid] [-subject subject]", exportCommand},
+  {"diff", "diff old-file new-file", diffCommand},
+  {"lint", "lint -rules file [-fail error|warning|info|none]", lintCommand},
+  {"repl", "repl [-rules file]", replCommand},
+}
+
+//the streams and flags shared by every command
+type cli struct {
+  stdin io.Reader
+  stdout io.Writer
+  stderr io.Writer
+  flags *flag.FlagSet
+  rules string
+}
+
+func run(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+  if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+    usage(stderr)
+    return 2
+  }
+
+  for _, cmd := range commands {
+    if cmd.name != args[0] {
+      continue
+    }
+
+    c := &cli{stdin: stdin, stdout: stdout, stderr: stderr}
+    c.flags = flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+    c.flags.SetOutput(stderr)
+    c.flags.Usage = func() {
+      fmt.Fprintf(stderr, "usage: patrun %v\n", cmd.usage)
+      c.flags.PrintDefaults()
+    }
+
+    return cmd.run(c, args[1:])
+  }
+
+  fmt.Fprintf(stderr, "patrun: unknown command %q\n", args[0])
+  usage(stderr)
+  return 2
+}
+
+func usage(w io.Writer) {
+  fmt.Fprintf(w, "usage: patrun <command> [flags] [arguments]\n\ncommands:\n")
+  for _, cmd := range commands {
+    fmt.Fprintf(w, "  %v\n", cmd.usage)
+  }
+}
+
+//parse the flags, load the rules and check the number of arguments
+func (c *cli) setup(args []string, min int, max int) (*patrun.Patrun, int) {
+  c.flags.StringVar(&c.rules, "rules", "", "rule file, or - for stdin")
+
+  if err := c.flags.Parse(args); err != nil {
+    return nil, 2
+  }
+
+  if c.rules == "" || c.flags.NArg() < min || c.flags.NArg() > max {
+    c.flags.Usage()
+    return nil, 2
+  }
+
+  pm, err := load(c.rules, c.stdin)
+  if err != nil {
+    return nil, c.fail(err)
+  }
+
+  return pm, 0
+}
+
+func (c *cli) fail(err error) int {
+  fmt.Fprintf(c.stderr, "patrun: %v\n", err)
+  return 1
+}
+
+func findCommand(exact bool) func(c *cli, args []string) int {
+  return func(c *cli, args []string) int {
+    pm, status := c.setup(args, 1, 1)
+    if pm == nil {
+      return status
+    }
+
+    subject, err := parseSubject(c.flags.Arg(0))
+    if err != nil {
+      return c.fail(err)
+    }
+
+    var data interface{}
+    if exact {
+      data = pm.FindExact(subject)
+    } else {
+      data = pm.Find(subject)
+    }
+
+    if data == nil {
+      fmt.Fprintf(c.stderr, "no match\n")
+      return 1
+    }
+
+    fmt.Fprintln(c.stdout, formatData(data))
+    return 0
+  }
+}
+
+func explainCommand(c *cli, args []string) int {
+  pm, status := c.setup(args, 1, 1)
+  if pm == nil {
+    return status
+  }
+
+  subject, err := parseSubject(c.flags.Arg(0))
+  if err != nil {
+    return c.fail(err)
+  }
+
+  e := pm.Explain(subject)
+
+  if e.Match == nil {
+    fmt.Fprintf(c.stderr, "no match\n")
+    return 1
+  }
+
+  fmt.Fprintf(c.stdout, "match: %v\n", formatMatch(e.Match))
+  fmt.Fprintf(c.stdout, "data:  %v\n", formatData(e.Data))
+  return 0
+}
+
+func listCommand(c *cli, args []string) int {
+  exact := c.flags.Bool("exact", false, "only list patterns with exactly the query's properties")
+
+  pm, status := c.setup(args, 0, 1)
+  if pm == nil {
+    return status
+  }
+
+  query, err := parseSubject(c.flags.Arg(0))
+  if err != nil {
+    return c.fail(err)
+  }
+
+  for item := range pm.Query(query, *exact) {
+    fmt.Fprintf(c.stdout, "%v -> %v\n", formatMatch(item.Match), formatData(item.Data))
+  }
+
+  return 0
+}
+
+func statsCommand(c *cli, args []string) int {
+  pm, status := c.setup(args, 0, 0)
+  if pm == nil {
+    return status
+  }
+
+  st := pm.Stats()
+
+  fmt.Fprintf(c.stdout, "patterns:  %v\n", st.Patterns)
+  fmt.Fprintf(c.stdout, "nodes:     %v\n", st.Nodes)
+  fmt.Fprintf(c.stdout, "max depth: %v\n", st.MaxDepth)
+  fmt.Fprintf(c.stdout, "avg depth: %.2f\n", st.AvgDepth)
+  fmt.Fprintf(c.stdout, "memory:    ~%v bytes\n", st.MemoryBytes)
+
+  var keys []string
+  for k := range st.Values {
+    keys = append(keys, k)
+  }
+  sort.Strings(keys)
+
+  if len(keys) > 0 {
+    fmt.Fprintf(c.stdout, "keys:\n")
+  }
+  for _, key := range keys {
+    fmt.Fprintf(c.stdout, "  %v: %v values, fan-out %v\n", key, st.Values[key], st.FanOut[key])
+  }
+
+  return 0
+}
+
+func exportCommand(c *cli, args []string) int {
+  format := c.flags.String("format", "json", "output format: json, yaml, dot or mermaid")
+  highlight := c.flags.String("subject", "", "highlight the path for this subject in dot and mermaid output")
+
+  pm, status := c.setup(args, 0, 0)
+  if pm == nil {
+    return status
+  }
+
+  var opts patrun.GraphOptions
+  if *highlight != "" {
+    subject, err := parseSubject(*highlight)
+    if err != nil {
+      return c.fail(err)
+    }
+    opts.Subject = subject
+  }
+
+  switch *format {
+  case "json":
+    b, err := pm.ToJSON()
+    if err != nil {
+      return c.fail(err)
+    }
+    fmt.Fprintf(c.stdout, "%s\n", b)
+
+  case "yaml":
+    rs, err := toRules(pm)
+    if err != nil {
+      return c.fail(err)
+    }
+    if err := rs.Encode(c.stdout); err != nil {
+      return c.fail(err)
+    }
+
+  case "dot":
+    fmt.Fprint(c.stdout, pm.ToDOTWith(opts))
+
+  case "mermaid":
+    fmt.Fprint(c.stdout, pm.ToMermaidWith(opts))
+
+  default:
+    return c.fail(fmt.Errorf("unknown format %q", *format))
+  }
+
+  return 0
+}
+
+func diffCommand(c *cli, args []string) int {
+  if err := c.flags.Parse(args); err != nil {
+    return 2
+  }
+  if c.flags.NArg() != 2 {
+    c.flags.Usage()
+    return 2
+  }
+  //stdin can only be read once
+  if c.flags.Arg(0) == "-" && c.flags.Arg(1) == "-" {
+    fmt.Fprintf(c.stderr, "patrun: only one of the files can be - for stdin\n")
+    return 2
+  }
+
+  old, err := load(c.flags.Arg(0), c.stdin)
+  if err != nil {
+    return c.fail(err)
+  }
+  new, err := load(c.flags.Arg(1), c.stdin)
+  if err != nil {
+    return c.fail(err)
+  }
+
+  d := patrun.Diff(old, new)
+  if d.Empty() {
+    return 0
+  }
+
+  fmt.Fprintf(c.stdout, "%v\n", d.ToUnified(c.flags.Arg(0), c.flags.Arg(1), formatData))
+  return 1
+}
+
+func lintCommand(c *cli, args []string) int {
+  failAt := c.flags.String("fail", "warning", "lowest severity that makes lint exit with status 1, or none")
+
+  pm, status := c.setup(args, 0, 0)
+  if pm == nil {
+    return status
+  }
+
+  var threshold = patrun.LintError + 1
+  for _, severity := range []patrun.LintSeverity{patrun.LintInfo, patrun.LintWarning, patrun.LintError} {
+    if severity.String() == *failAt {
+      threshold = severity
+    }
+  }
+  if threshold > patrun.LintError && *failAt != "none" {
+    return c.fail(fmt.Errorf("unknown severity %q", *failAt))
+  }
+
+  status = 0
+  for _, finding := range pm.Lint() {
+    fmt.Fprintln(c.stdout, finding)
+    if finding.Severity >= threshold {
+      status = 1
+    }
+  }
+
+  return status
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+  "os"
+  "path/filepath"
+  "strings"
+  "testing"
+)
+
+const testRules = `rules:
+  - match: {}
+    data: default
+  - match: {country: IE}
+    data: "0.23"
+  - match: {country: IE, type: food}
+    data: "0.048"
+  - match: {country: UK}
+    data: "0.20"
+`
+
+const testJSON = `[{"Match":{},"Data":"default"},{"Match":{"country":"IE"},"Data":"0.25"},{"Match":{"country":"IE","type":"food"},"Data":"0.048"},{"Match":{"country":"DE"},"Data":0.19}]`
+
+func writeFile(t *testing.T, name string, contents string) string {
+  path := filepath.Join(t.TempDir(), name)
+  if err := os.WriteFile(path, []byte(contents), 0666); err != nil {
+    t.Fatal(err)
+  }
+  return path
+}
+
+func runCLI(stdin string, args ...string) (int, string, string) {
+  var stdout, stderr strings.Builder
+
+  status := run(args, strings.NewReader(stdin), &stdout, &stderr)
+
+  return status, stdout.String(), stderr.String()
+}
+
+func TestCLI(t *testing.T) {
+  yaml := writeFile(t, "tax.yaml", testRules)
+  json := writeFile(t, "tax.json", testJSON)
+
+  var tests = []struct{
+    args []string
+    status int
+    out string
+  }{
+    {[]string{"find", "-rules", yaml, "country:IE, type:food"}, 0, "0.048\n"},
+    {[]string{"find", "-rules", yaml, `{"country":"IE","type":"other"}`}, 0, "0.23\n"},
+    {[]string{"find", "-rules", json, "country:DE"}, 0, "0.19\n"},
+    {[]string{"find-exact", "-rules", yaml, "country:IE,type:other"}, 1, ""},
+    {[]string{"find-exact", "-rules", yaml, "country:IE"}, 0, "0.23\n"},
+    {[]string{"explain", "-rules", yaml, "country:IE,type:other"}, 0, "match: country:IE\ndata:  0.23\n"},
+    {[]string{"list", "-rules", yaml, "country:*"}, 0, " -> default\ncountry:IE -> 0.23\ncountry:IE, type:food -> 0.048\ncountry:UK -> 0.20\n"},
+    {[]string{"list", "-rules", yaml, "-exact", "country:*"}, 0, " -> default\ncountry:IE -> 0.23\ncountry:UK -> 0.20\n"},
+    {[]string{"stats", "-rules", yaml}, 0, "patterns:  4\nnodes:     5\nmax depth: 2\navg depth: 1.00\n"},
+    {[]string{"export", "-rules", yaml}, 0, `[{"Match":{},"Data":"default"},{"Match":{"country":"IE"},"Data":"0.23"},{"Match":{"country":"IE","type":"food"},"Data":"0.048"},{"Match":{"country":"UK"},"Data":"0.20"}]` + "\n"},
+    {[]string{"export", "-rules", json, "-format", "yaml"}, 0, "version: 1\nrules:\n  - match: {}\n    data: default\n  - match:\n      country: DE\n    data: 0.19\n"},
+    {[]string{"export", "-rules", yaml, "-format", "dot", "-subject", "country:UK"}, 0, "digraph patrun {\n"},
+    {[]string{"export", "-rules", yaml, "-format", "mermaid"}, 0, "flowchart TD\n"},
+    {[]string{"diff", yaml, json}, 1, "--- " + yaml + "\n+++ " + json + "\n+country:DE -> <0.19>\n-country:IE -> <0.23>\n+country:IE -> <0.25>\n-country:UK -> <0.20>\n"},
+    {[]string{"diff", yaml, yaml}, 0, ""},
+  }
+
+  for _, test := range tests {
+    status, out, errs := runCLI("", test.args...)
+
+    if status != test.status || !strings.HasPrefix(out, test.out) {
+      t.Errorf("patrun %v should exit %v with %q, got %v with %q %q", strings.Join(test.args, " "), test.status, test.out, status, out, errs)
+    }
+  }
+}
+
+func TestCLIStdin(t *testing.T) {
+  status, out, _ := runCLI(testRules, "find", "-rules", "-", "country:UK")
+  if status != 0 || out != "0.20\n" {
+    t.Error("find should read rules from stdin", status, out)
+  }
+
+  status, out, _ = runCLI(testJSON, "find", "-rules", "-", "country:IE")
+  if status != 0 || out != "0.25\n" {
+    t.Error("find should read JSON rules from stdin", status, out)
+  }
+}
+
+func TestCLILint(t *testing.T) {
+  clean := writeFile(t, "tax.yaml", testRules)
+  if status, out, _ := runCLI("", "lint", "-rules", clean); status != 0 || out != "" {
+    t.Error("lint should pass a clean rule set", status, out)
+  }
+
+  lint := writeFile(t, "lint.yaml", testRules + "  - match: {country: UK, type: food}\n    data: \"0.20\"\n  - match: {region: EU}\n    data: \"0.10\"\n")
+
+  expected := "warning: redundant: country:UK, type:food: data <0.20> is the same as the data of country:UK\n" +
+    "info: shadowed: region:EU: subjects like country:IE, region:EU get the data of country:IE instead\n"
+
+  if status, out, _ := runCLI("", "lint", "-rules", lint); status != 1 || !strings.HasPrefix(out, expected) {
+    t.Errorf("lint should report findings, got %v %q", status, out)
+  }
+  if status, _, _ := runCLI("", "lint", "-rules", lint, "-fail", "error"); status != 0 {
+    t.Error("lint should only fail at the -fail severity", status)
+  }
+  if status, _, errs := runCLI("", "lint", "-rules", lint, "-fail", "bad"); status != 1 || !strings.Contains(errs, "unknown severity") {
+    t.Error("lint should report unknown severities", status, errs)
+  }
+}
+
+func TestCLIErrors(t *testing.T) {
+  bad := writeFile(t, "bad.yaml", "rules:\n  - match: {a: 1}\n")
+
+  status, _, errs := runCLI("", "find", "-rules", bad, "a:1")
+  if status != 1 || !strings.Contains(errs, "bad.yaml:2: rule is missing data") {
+    t.Error("find should report rule file errors", status, errs)
+  }
+
+  if status, _, _ := runCLI("", "find", "a:1"); status != 2 {
+    t.Error("find without -rules should be a usage error", status)
+  }
+  if status, _, _ := runCLI("", "unknown"); status != 2 {
+    t.Error("unknown command should be a usage error", status)
+  }
+  if status, _, _ := runCLI(""); status != 2 {
+    t.Error("no command should be a usage error", status)
+  }
+
+  yaml := writeFile(t, "tax.yaml", testRules)
+  if status, _, errs := runCLI("", "find", "-rules", yaml, "{bad"); status != 1 || !strings.Contains(errs, "invalid subject") {
+    t.Error("find should report bad JSON subjects", status, errs)
+  }
+  if status, _, errs := runCLI("", "export", "-rules", yaml, "-format", "xml"); status != 1 || !strings.Contains(errs, "unknown format") {
+    t.Error("export should report unknown formats", status, errs)
+  }
+  if status, _, errs := runCLI(testRules, "diff", "-", "-"); status != 2 || !strings.Contains(errs, "only one") {
+    t.Error("diff should refuse to read stdin twice", status, errs)
+  }
+  if status, _, errs := runCLI("", "find", "-rules", yaml, "country:IE,type"); status != 1 || !strings.Contains(errs, "has no value") {
+    t.Error("find should report malformed subjects", status, errs)
+  }
+
+  partial := writeFile(t, "partial.yaml", "rules:\n  - match: {a: 1}\n    data: x\n")
+  for _, command := range []string{"find", "explain"} {
+    if status, out, errs := runCLI("", command, "-rules", partial, "a:2"); status != 1 || out != "" || errs != "no match\n" {
+      t.Errorf("%v should report no match on stderr, got %v %q %q", command, status, out, errs)
+    }
+  }
+}
@@ -0,0 +1,311 @@
+package main
+
+import (
+  "bufio"
+  "errors"
+  "fmt"
+  "io"
+  "os"
+  "path/filepath"
+  "sort"
+  "strings"
+
+  "github.com/colmharte/patrun-golang/patrun"
+)
+
+const replHelp = `commands:
+  add <pattern> => <data>   add a pattern, eg add a:1,b:2 => X
+  remove <pattern>          remove a pattern
+  find <subject>            show the data Find returns
+  find-exact <subject>      show the data FindExact returns
+  explain <subject>         show the pattern responsible for a subject's result
+  list [query]              list the patterns matching a query, eg list a:*
+  stats                     show statistics about the rule set
+  undo                      undo the last add or remove
+  save [file]               save the rules, to the file they were loaded from by default
+  history                   show the commands entered
+  help                      show this help
+  quit                      leave the repl
+`
+
+var replCommands = []string{"add", "explain", "find", "find-exact", "help", "history", "list", "quit", "remove", "save", "stats", "undo"}
+
+//an interactive session over a rule set
+type repl struct {
+  pm *patrun.Patrun
+  path string
+  out io.Writer
+  undo []*patrun.Patrun
+  history []string
+}
+
+func replCommand(c *cli, args []string) int {
+  c.flags.StringVar(&c.rules, "rules", "", "rule file to load and save, optional")
+
+  if err := c.flags.Parse(args); err != nil || c.flags.NArg() > 0 {
+    c.flags.Usage()
+    return 2
+  }
+
+  r := &repl{pm: &patrun.Patrun{}, path: c.rules, out: c.stdout}
+
+  if c.rules != "" {
+    pm, err := load(c.rules, c.stdin)
+    if err != nil && !errors.Is(err, os.ErrNotExist) {
+      return c.fail(err)
+    }
+    if pm != nil {
+      r.pm = pm
+    }
+  }
+
+  fmt.Fprintf(c.stdout, "patrun repl, type help for the commands\n")
+
+  //use the line editor when stdin is a terminal, otherwise read plain lines
+  if f, ok := c.stdin.(*os.File); ok {
+    if state, err := makeRaw(int(f.Fd())); err == nil {
+      defer restoreTerminal(int(f.Fd()), state)
+
+      editor := &lineEditor{bufio.NewReader(f), &crlfWriter{c.stdout}, "patrun> ", &r.history, r.complete}
+      r.out = editor.out
+
+      for {
+        line, err := editor.readLine()
+        if err != nil || !r.exec(line) {
+          return 0
+        }
+      }
+    }
+  }
+
+  scanner := bufio.NewScanner(c.stdin)
+  for scanner.Scan() {
+    if !r.exec(scanner.Text()) {
+      break
+    }
+  }
+
+  return 0
+}
+
+//run a command, returning false when the session should end
+func (r *repl) exec(line string) bool {
+  line = strings.TrimSpace(line)
+  if line == "" {
+    return true
+  }
+
+  r.history = append(r.history, line)
+
+  name, arg, _ := strings.Cut(line, " ")
+  arg = strings.TrimSpace(arg)
+
+  switch name {
+  case "add":
+    pattern, data, ok := strings.Cut(arg, "=>")
+    if !ok || strings.TrimSpace(data) == "" {
+      fmt.Fprintf(r.out, "usage: add <pattern> => <data>\n")
+      return true
+    }
+    pat, err := patrun.ParsePattern(pattern)
+    if err != nil {
+      fmt.Fprintf(r.out, "%v\n", err)
+      return true
+    }
+    r.checkpoint()
+    r.pm.Add(pat, strings.TrimSpace(data))
+
+  case "remove":
+    pat, err := patrun.ParsePattern(arg)
+    if err != nil {
+      fmt.Fprintf(r.out, "%v\n", err)
+      return true
+    }
+
+    //FindExact can give the data of a shorter pattern, only remove added ones
+    if _, found := r.pm.Lookup(pat); !found {
+      fmt.Fprintf(r.out, "no pattern %v\n", arg)
+      return true
+    }
+    r.checkpoint()
+    r.pm.Remove(pat)
+
+  case "find", "find-exact":
+    pat, err := patrun.ParsePattern(arg)
+    if err != nil {
+      fmt.Fprintf(r.out, "%v\n", err)
+      return true
+    }
+
+    var data interface{}
+    if name == "find" {
+      data = r.pm.Find(pat)
+    } else {
+      data = r.pm.FindExact(pat)
+    }
+
+    if data == nil {
+      fmt.Fprintf(r.out, "no match\n")
+    } else {
+      fmt.Fprintf(r.out, "%v\n", formatData(data))
+    }
+
+  case "explain":
+    pat, err := patrun.ParsePattern(arg)
+    if err != nil {
+      fmt.Fprintf(r.out, "%v\n", err)
+      return true
+    }
+
+    e := r.pm.Explain(pat)
+    if e.Match == nil {
+      fmt.Fprintf(r.out, "no match\n")
+    } else {
+      fmt.Fprintf(r.out, "match: %v\ndata:  %v\n", formatMatch(e.Match), formatData(e.Data))
+    }
+
+  case "list":
+    pat, err := patrun.ParsePattern(arg)
+    if err != nil {
+      fmt.Fprintf(r.out, "%v\n", err)
+      return true
+    }
+
+    for item := range r.pm.Query(pat, false) {
+      fmt.Fprintf(r.out, "%v -> %v\n", formatMatch(item.Match), formatData(item.Data))
+    }
+
+  case "stats":
+    st := r.pm.Stats()
+    fmt.Fprintf(r.out, "patterns: %v, nodes: %v, max depth: %v\n", st.Patterns, st.Nodes, st.MaxDepth)
+
+  case "undo":
+    if len(r.undo) == 0 {
+      fmt.Fprintf(r.out, "nothing to undo\n")
+      return true
+    }
+    r.pm = r.undo[len(r.undo) - 1]
+    r.undo = r.undo[:len(r.undo) - 1]
+
+  case "save":
+    if err := r.save(arg); err != nil {
+      fmt.Fprintf(r.out, "error: %v\n", err)
+    }
+
+  case "history":
+    for k, item := range r.history[:len(r.history) - 1] {
+      fmt.Fprintf(r.out, "%4d  %v\n", k + 1, item)
+    }
+
+  case "help":
+    fmt.Fprint(r.out, replHelp)
+
+  case "quit", "exit":
+    return false
+
+  default:
+    fmt.Fprintf(r.out, "unknown command %q, type help for the commands\n", name)
+  }
+
+  return true
+}
+
+//keep a copy of the rules so the next change can be undone
+func (r *repl) checkpoint() {
+  r.undo = append(r.undo, r.pm.Clone())
+}
+
+func (r *repl) save(path string) error {
+  if path == "" {
+    path = r.path
+  }
+  if path == "" || path == "-" {
+    return errors.New("no file to save to, use save <file>")
+  }
+
+  var data []byte
+
+  if strings.EqualFold(filepath.Ext(path), ".json") {
+    b, err := r.pm.ToJSON()
+    if err != nil {
+      return err
+    }
+    data = append(b, '\n')
+  } else {
+    rs, err := toRules(r.pm)
+    if err != nil {
+      return err
+    }
+    data = rs.Marshal()
+  }
+
+  if err := os.WriteFile(path, data, 0666); err != nil {
+    return err
+  }
+
+  r.path = path
+  fmt.Fprintf(r.out, "saved %v\n", path)
+
+  return nil
+}
+
+//complete command names, then the property names and values registered in the tree
+func (r *repl) complete(line string) (string, []string) {
+  var options []string
+
+  if !strings.Contains(line, " ") {
+    for _, name := range replCommands {
+      if strings.HasPrefix(name, line) {
+        options = append(options, name + " ")
+      }
+    }
+    return line, options
+  }
+
+  //nothing to complete in the data after =>
+  if strings.Contains(line, "=>") {
+    return "", nil
+  }
+
+  word := line[strings.LastIndexAny(line, " ,") + 1:]
+  key, val, isValue := strings.Cut(word, ":")
+
+  var values = map[string]map[string]bool{}
+  for item := range r.pm.All() {
+    for k, v := range item.Match {
+      if values[k] == nil {
+        values[k] = map[string]bool{}
+      }
+      values[k][v] = true
+    }
+  }
+
+  if isValue {
+    for v := range values[key] {
+      if strings.HasPrefix(v, val) {
+        options = append(options, key + ":" + v)
+      }
+    }
+  } else {
+    for k := range values {
+      if strings.HasPrefix(k, key) {
+        options = append(options, k + ":")
+      }
+    }
+  }
+
+  sort.Strings(options)
+
+  return word, options
+}
+
+//raw mode terminals need \r\n to start a new line
+type crlfWriter struct {
+  w io.Writer
+}
+
+func (c *crlfWriter) Write(p []byte) (int, error) {
+  _, err := c.w.Write([]byte(strings.ReplaceAll(strings.ReplaceAll(string(p), "\r\n", "\n"), "\n", "\r\n")))
+
+  return len(p), err
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+  "bufio"
+  "io"
+  "os"
+  "path/filepath"
+  "reflect"
+  "strings"
+  "testing"
+
+  "github.com/colmharte/patrun-golang/patrun"
+)
+
+func TestReplSession(t *testing.T) {
+  path := filepath.Join(t.TempDir(), "tax.yaml")
+
+  input := strings.Join([]string{
+    "add country:IE => 0.23",
+    "add country:IE,type:food => 0.048",
+    "find country:IE,type:food",
+    "remove country:IE,type:food",
+    "find country:IE,type:food",
+    "undo",
+    "explain country:IE,type:food",
+    "list country:*",
+    "save " + path,
+    "bogus",
+    "quit",
+    "find country:IE",
+  }, "\n")
+
+  status, out, _ := runCLI(input, "repl")
+  if status != 0 {
+    t.Fatalf("status %v", status)
+  }
+
+  expected := "patrun repl, type help for the commands\n" +
+    "0.048\n" +
+    "0.23\n" +
+    "match: country:IE, type:food\ndata:  0.048\n" +
+    "country:IE -> 0.23\ncountry:IE, type:food -> 0.048\n" +
+    "saved " + path + "\n" +
+    "unknown command \"bogus\", type help for the commands\n"
+
+  if out != expected {
+    t.Errorf("got:\n%v\nexpected:\n%v", out, expected)
+  }
+
+  //the saved file loads back and later commands run against it
+  status, out, _ = runCLI("find country:IE,type:food\nundo\n", "repl", "-rules", path)
+  if status != 0 || out != "patrun repl, type help for the commands\n0.048\nnothing to undo\n" {
+    t.Errorf("reload: %v %q", status, out)
+  }
+
+  //only patterns that were added can be removed
+  status, out, _ = runCLI("add a:1 => A\nadd a:1,b:2,c:3 => C\nremove a:1,b:2\nremove a:1,b\nremove a:1\nlist\n", "repl")
+  if status != 0 || out != "patrun repl, type help for the commands\nno pattern a:1,b:2\npatrun: invalid pattern: \"b\" has no value\na:1, b:2, c:3 -> C\n" {
+    t.Errorf("remove: %v %q", status, out)
+  }
+
+  //patterns are parsed strictly by every command
+  status, out, _ = runCLI("add a:1,b => X\nfind a:1,b\nexplain a:1,b\nlist\n", "repl")
+  if status != 0 || out != "patrun repl, type help for the commands\n" + strings.Repeat("patrun: invalid pattern: \"b\" has no value\n", 3) {
+    t.Errorf("parse: %v %q", status, out)
+  }
+
+  //a missing rule file starts an empty session that saves to it
+  missing := filepath.Join(t.TempDir(), "new.json")
+  runCLI("add a:1 => A\nsave\n", "repl", "-rules", missing)
+  if b, err := os.ReadFile(missing); err != nil || string(b) != `[{"Match":{"a":"1"},"Data":"A"}]` + "\n" {
+    t.Errorf("save json: %q %v", b, err)
+  }
+}
+
+func TestReplComplete(t *testing.T) {
+  r := &repl{}
+  r.pm = mustLoad(t, testRules)
+
+  var tests = []struct{
+    line string
+    word string
+    options []string
+  }{
+    {"fi", "fi", []string{"find ", "find-exact "}},
+    {"he", "he", []string{"help "}},
+    {"find c", "c", []string{"country:"}},
+    {"find country:", "country:", []string{"country:IE", "country:UK"}},
+    {"find country:IE,t", "t", []string{"type:"}},
+    {"add a:1 => x", "", nil},
+  }
+
+  for _, test := range tests {
+    word, options := r.complete(test.line)
+    if word != test.word || !reflect.DeepEqual(options, test.options) {
+      t.Errorf("%q: got %q %q", test.line, word, options)
+    }
+  }
+}
+
+func mustLoad(t *testing.T, src string) *patrun.Patrun {
+  pm, err := load(writeFile(t, "rules.yaml", src), nil)
+  if err != nil {
+    t.Fatal(err)
+  }
+  return pm
+}
+
+func TestLineEditor(t *testing.T) {
+  r := &repl{pm: mustLoad(t, testRules), history: []string{"list", "stats"}}
+
+  //tab completes the command, backspace, the up arrow recalls history and ctrl-d ends input
+  keys := "fi\t c\tIE\x7f\x7fUK\r\x1b[A\x1b[A\r\x04"
+  editor := &lineEditor{bufio.NewReader(strings.NewReader(keys)), io.Discard, "> ", &r.history, r.complete}
+
+  var lines []string
+  for {
+    line, err := editor.readLine()
+    if err != nil {
+      if err != io.EOF {
+        t.Fatal(err)
+      }
+      break
+    }
+    lines = append(lines, line)
+  }
+
+  if !reflect.DeepEqual(lines, []string{"find country:UK", "list"}) {
+    t.Errorf("got %q", lines)
+  }
+}
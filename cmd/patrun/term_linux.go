@@ -0,0 +1,46 @@
+//go:build linux
+
+package main
+
+import (
+  "syscall"
+  "unsafe"
+)
+
+type terminalState struct {
+  termios syscall.Termios
+}
+
+func ioctl(fd int, request uintptr, termios *syscall.Termios) error {
+  _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), request, uintptr(unsafe.Pointer(termios)))
+  if errno != 0 {
+    return errno
+  }
+
+  return nil
+}
+
+//put the terminal into raw mode so keys can be read one at a time, fails if fd isn't a terminal
+func makeRaw(fd int) (*terminalState, error) {
+  var state terminalState
+
+  if err := ioctl(fd, syscall.TCGETS, &state.termios); err != nil {
+    return nil, err
+  }
+
+  raw := state.termios
+  raw.Iflag &^= syscall.ICRNL | syscall.IXON
+  raw.Lflag &^= syscall.ECHO | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+  raw.Cc[syscall.VMIN] = 1
+  raw.Cc[syscall.VTIME] = 0
+
+  if err := ioctl(fd, syscall.TCSETS, &raw); err != nil {
+    return nil, err
+  }
+
+  return &state, nil
+}
+
+func restoreTerminal(fd int, state *terminalState) error {
+  return ioctl(fd, syscall.TCSETS, &state.termios)
+}
@@ -0,0 +1,18 @@
+//go:build !linux
+
+package main
+
+import (
+  "errors"
+)
+
+type terminalState struct{}
+
+//line editing is only supported on linux, elsewhere the repl reads whole lines
+func makeRaw(fd int) (*terminalState, error) {
+  return nil, errors.New("raw terminal mode is not supported")
+}
+
+func restoreTerminal(fd int, state *terminalState) error {
+  return nil
+}
@@ -0,0 +1,104 @@
+package patrun
+
+import (
+  "sync"
+)
+
+//Return the match for each subject, in the same order as the subjects. This
+//is the same as calling Find for every subject, but subjects that have the
+//same property names as the one before them reuse its sorted keys.
+func (p *Patrun) FindMany(subjects []map[string]string) []interface{} {
+  results := make([]interface{}, len(subjects))
+
+  p.findBatch(subjects, results)
+
+  return results
+}
+
+//Same as FindMany but splits the subjects into contiguous chunks that are
+//matched by at most workers goroutines. The matcher must not be modified
+//while the call is in progress.
+func (p *Patrun) FindManyParallel(subjects []map[string]string, workers int) []interface{} {
+  results := make([]interface{}, len(subjects))
+
+  if workers > len(subjects) {
+    workers = len(subjects)
+  }
+
+  if workers <= 1 {
+    p.findBatch(subjects, results)
+    return results
+  }
+
+  var size = (len(subjects) + workers - 1) / workers
+  var wg sync.WaitGroup
+
+  for start := 0; start < len(subjects); start += size {
+    end := min(start + size, len(subjects))
+
+    wg.Add(1)
+    go func(start, end int) {
+      defer wg.Done()
+      p.findBatch(subjects[start:end], results[start:end])
+    }(start, end)
+  }
+
+  wg.Wait()
+
+  return results
+}
+
+func (p *Patrun) findBatch(subjects []map[string]string, results []interface{}) {
+  //middleware expects a subject per call so there is nothing to share
+  if p.chain != nil {
+    for k := range subjects {
+      results[k] = p.findItem(subjects[k], false)
+    }
+    return
+  }
+
+  s := findPool.Get().(*findScratch)
+  s.pairs = s.pairs[:0]
+
+  for k := range subjects {
+    var pat = subjects[k]
+
+    if sameKeys(s.pairs, pat) {
+      for i := range s.pairs {
+        s.pairs[i].val = pat[s.pairs[i].key]
+      }
+    } else {
+      s.pairs = appendMapPairs(s.pairs[:0], pat)
+    }
+
+    if !p.accepts(s.pairs) {
+      results[k] = nil
+      continue
+    }
+
+    lastData, lastModifier := p.walk(s, false, false)
+
+    if lastModifier != nil {
+      lastData = lastModifier.Find(p, pat, lastData)
+    }
+
+    results[k] = lastData
+  }
+
+  findPool.Put(s)
+}
+
+//check if the subject has exactly the keys of the previous, already sorted, subject
+func sameKeys(pairs []pair, pat map[string]string) bool {
+  if len(pairs) != len(pat) {
+    return false
+  }
+
+  for k := range pairs {
+    if _, ok := pat[pairs[k].key]; !ok {
+      return false
+    }
+  }
+
+  return true
+}
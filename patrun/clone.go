@@ -0,0 +1,61 @@
+package patrun
+
+import (
+  "slices"
+)
+
+//Modifiers that keep state can also implement ModifierCloner so that Clone
+//gives the copy its own state and removing from the copy doesn't change the
+//original.
+type ModifierCloner interface {
+  CloneModifier() Modifiers
+}
+
+//Return a deep copy of the matcher. The decision tree is copied so patterns
+//can be added to or removed from the copy without changing the original.
+//Modifiers are copied if they implement ModifierCloner. The data, other
+//modifiers, Customiser, Schema and middleware are shared with the original.
+func (p *Patrun) Clone() *Patrun {
+  return p.CloneWith(nil)
+}
+
+//Same as Clone but the data stored against each pattern is copied using the
+//custom function. A nil function shares the data with the original.
+func (p *Patrun) CloneWith(copyData func(data interface{}) interface{}) *Patrun {
+  c := &Patrun{Custom: p.Custom, Schema: p.Schema}
+
+  if p.tree.key != "" {
+    c.tree = cloneNode(p.tree, copyData)
+  }
+
+  //the chain is given the matcher on each call so it can be shared
+  c.middleware = slices.Clone(p.middleware)
+  c.chain = p.chain
+
+  return c
+}
+
+func cloneNode(n node, copyData func(data interface{}) interface{}) node {
+  var item = node{n.key, make(map[string]node, len(n.value)), n.data, n.modifier}
+
+  if copyData != nil && n.data != nil {
+    item.data = copyData(n.data)
+  }
+
+  item.modifier = cloneModifier(n.modifier)
+
+  for k, v := range n.value {
+    item.value[k] = cloneNode(v, copyData)
+  }
+
+  return item
+}
+
+//copy the modifier if it keeps state, otherwise share it
+func cloneModifier(m Modifiers) Modifiers {
+  if c, ok := m.(ModifierCloner); ok {
+    return c.CloneModifier()
+  }
+
+  return m
+}
@@ -0,0 +1,146 @@
+package patrun
+
+import (
+  "context"
+  "fmt"
+)
+
+//Customisers can also implement ContextCustomiser to see the context passed
+//to AddContext. It is used in place of CustomiserE and Add.
+type ContextCustomiser interface {
+  AddContext(ctx context.Context, pm *Patrun, pat map[string]string, data interface{}) (Modifiers, error)
+}
+
+//Modifiers can also implement ContextModifiers to see the context passed to
+//FindContext, FindExactContext and RemoveContext. They are used in place of
+//ModifiersE and the plain Find and Remove. The error returning methods pass
+//context.Background.
+type ContextModifiers interface {
+  FindContext(ctx context.Context, pm *Patrun, pat map[string]string, data interface{}) (interface{}, error)
+  RemoveContext(ctx context.Context, pm *Patrun, pat map[string]string, data interface{}) (bool, error)
+}
+
+//Same as AddE but the context is passed to a ContextCustomiser. Nothing is
+//added if the context is already done.
+func (p *Patrun) AddContext(ctx context.Context, pat map[string]string, data interface{}) error {
+  if err := ctx.Err(); err != nil {
+    return err
+  }
+
+  if data == nil {
+    return fmt.Errorf("%w: %v: no data", ErrInvalidPattern, describeMatch(pat))
+  }
+
+  if err := p.Validate(pat); err != nil {
+    return err
+  }
+
+  var custom Modifiers
+  var err error
+
+  switch c := p.Custom.(type) {
+  case ContextCustomiser:
+    custom, err = c.AddContext(ctx, p, pat, data)
+  case CustomiserE:
+    custom, err = c.AddE(p, pat, data)
+  case nil:
+  default:
+    custom = c.Add(p, pat, data)
+  }
+
+  if err != nil {
+    return err
+  }
+
+  p.insert(pat, modifiedData(custom, data), custom)
+
+  return nil
+}
+
+//Same as RemoveE but the context is passed to a ContextModifiers. Nothing is
+//removed if the context is already done.
+func (p *Patrun) RemoveContext(ctx context.Context, pat map[string]string) error {
+  if err := ctx.Err(); err != nil {
+    return err
+  }
+
+  if _, found := p.lookup(pat); !found {
+    return fmt.Errorf("%w: %v", ErrNotFound, describeMatch(pat))
+  }
+
+  return p.remove(pat, func(modifier Modifiers, data interface{}) (bool, error) {
+    switch m := modifier.(type) {
+    case ContextModifiers:
+      return m.RemoveContext(ctx, p, pat, data)
+    case ModifiersE:
+      return m.RemoveE(p, pat, data)
+    }
+    return modifier.Remove(p, pat, data), nil
+  })
+}
+
+//Same as FindE but the context is passed to a ContextModifiers. The context's
+//error is returned if it is already done.
+func (p *Patrun) FindContext(ctx context.Context, pat map[string]string) (interface{}, error) {
+  return p.findContext(ctx, pat, false)
+}
+
+//Same as FindContext but only matches where all properties match will be returned.
+func (p *Patrun) FindExactContext(ctx context.Context, pat map[string]string) (interface{}, error) {
+  return p.findContext(ctx, pat, true)
+}
+
+func (p *Patrun) findContext(ctx context.Context, pat map[string]string, exact bool) (interface{}, error) {
+  if err := ctx.Err(); err != nil {
+    return nil, err
+  }
+
+  data, err := p.find(ctx, pat, exact)
+  if err != nil {
+    return nil, err
+  }
+
+  if data == nil {
+    return nil, fmt.Errorf("%w: %v", ErrNotFound, describeMatch(pat))
+  }
+
+  return data, nil
+}
+
+//find the data for the subject, calling the most capable hook of the modifier.
+//This is the end of the middleware chain so nothing matching isn't an error.
+func findNext(ctx context.Context, p *Patrun, pat map[string]string, exact bool) (interface{}, error) {
+  if err := p.Validate(pat); err != nil {
+    return nil, err
+  }
+
+  trace, _ := ctx.Value(explainKey{}).(*explainTrace)
+  if trace != nil && trace.pm != p {
+    trace = nil
+  }
+
+  s := findPool.Get().(*findScratch)
+
+  s.pairs = appendMapPairs(s.pairs[:0], pat)
+  lastData, lastModifier := p.walk(s, exact, trace != nil)
+
+  if trace != nil {
+    trace.result.Match = nil
+    if lastData != nil {
+      trace.result.Match = matchMap(s.match)
+    }
+  }
+
+  findPool.Put(s)
+
+  switch m := lastModifier.(type) {
+  case ContextModifiers:
+    return m.FindContext(ctx, p, pat, lastData)
+  case ModifiersE:
+    return m.FindE(p, pat, lastData)
+  case nil:
+    return lastData, nil
+  default:
+    return m.Find(p, pat, lastData), nil
+  }
+}
@@ -0,0 +1,248 @@
+// Package custom provides ready made Customisers for patrun.
+//
+// Each type implements patrun.Customiser and can be set as the Custom field of
+// a matcher, or combined with Chain:
+//
+//  pm := patrun.Patrun{Custom: custom.Chain(custom.ConstantProperties{"env": "prod"}, &custom.MultiValue{})}
+//
+// Customisers that keep state per pattern, such as MultiValue and RefCounted,
+// keep it in the modifier stored against the pattern so it is copied by
+// Clone and lost when the pattern is finally removed.
+package custom
+
+import (
+  "github.com/colmharte/patrun-golang/patrun"
+)
+
+//MultiValue stores every value added for a pattern instead of replacing the
+//previous one. Find returns the values as a []interface{} in the order they
+//were added, or just the most recent value when Top is set. Remove pops the
+//most recent value and only removes the pattern when none are left.
+type MultiValue struct {
+  Top bool
+}
+
+type multiValueModifier struct {
+  items []interface{}
+  top bool
+}
+
+func (c *MultiValue) Add(pm *patrun.Patrun, pat map[string]string, data interface{}) patrun.Modifiers {
+  var items []interface{}
+
+  if prior, ok := priorModifier[*multiValueModifier](pm, pat); ok {
+    items = append(items, prior.items...)
+  }
+
+  return &multiValueModifier{append(items, data), c.Top}
+}
+
+func (m *multiValueModifier) Find(pm *patrun.Patrun, pat map[string]string, data interface{}) interface{} {
+  if data == nil || len(m.items) == 0 {
+    return nil
+  }
+
+  if m.top {
+    return m.items[len(m.items) - 1]
+  }
+
+  return append([]interface{}(nil), m.items...)
+}
+
+func (m *multiValueModifier) Data() interface{} {
+  if len(m.items) == 0 {
+    return nil
+  }
+  return m.items[len(m.items) - 1]
+}
+
+func (m *multiValueModifier) CloneModifier() patrun.Modifiers {
+  return &multiValueModifier{append([]interface{}(nil), m.items...), m.top}
+}
+
+func (m *multiValueModifier) Remove(pm *patrun.Patrun, pat map[string]string, data interface{}) bool {
+  if len(m.items) > 0 {
+    m.items = m.items[:len(m.items) - 1]
+  }
+
+  return len(m.items) == 0
+}
+
+//ConstantProperties adds its properties to every pattern, overwriting any
+//the pattern already has. Subjects must include them to match.
+type ConstantProperties map[string]string
+
+func (c ConstantProperties) Add(pm *patrun.Patrun, pat map[string]string, data interface{}) patrun.Modifiers {
+  for k, v := range c {
+    pat[k] = v
+  }
+
+  return nil
+}
+
+//TransformResult applies the function to the data Find returns. It isn't
+//called when there is no match.
+type TransformResult func(data interface{}) interface{}
+
+type transformModifier TransformResult
+
+func (c TransformResult) Add(pm *patrun.Patrun, pat map[string]string, data interface{}) patrun.Modifiers {
+  return transformModifier(c)
+}
+
+func (m transformModifier) Find(pm *patrun.Patrun, pat map[string]string, data interface{}) interface{} {
+  if data == nil {
+    return nil
+  }
+  return m(data)
+}
+
+func (m transformModifier) Remove(pm *patrun.Patrun, pat map[string]string, data interface{}) bool {
+  return true
+}
+
+//ReadOnly vetoes every Remove, patterns can still be replaced with Add.
+type ReadOnly struct {}
+
+type readOnlyModifier struct {}
+
+func (c ReadOnly) Add(pm *patrun.Patrun, pat map[string]string, data interface{}) patrun.Modifiers {
+  return readOnlyModifier{}
+}
+
+func (m readOnlyModifier) Find(pm *patrun.Patrun, pat map[string]string, data interface{}) interface{} {
+  return data
+}
+
+func (m readOnlyModifier) Remove(pm *patrun.Patrun, pat map[string]string, data interface{}) bool {
+  return false
+}
+
+//RefCounted counts how many times each pattern has been added. Each Add
+//replaces the data as usual, but Remove only removes the pattern when it has
+//been called as many times as Add.
+type RefCounted struct {}
+
+type refCountedModifier struct {
+  count int
+}
+
+func (c RefCounted) Add(pm *patrun.Patrun, pat map[string]string, data interface{}) patrun.Modifiers {
+  var count = 1
+
+  if prior, ok := priorModifier[*refCountedModifier](pm, pat); ok {
+    count += prior.count
+  }
+
+  return &refCountedModifier{count}
+}
+
+func (m *refCountedModifier) Find(pm *patrun.Patrun, pat map[string]string, data interface{}) interface{} {
+  return data
+}
+
+func (m *refCountedModifier) CloneModifier() patrun.Modifiers {
+  return &refCountedModifier{m.count}
+}
+
+func (m *refCountedModifier) Remove(pm *patrun.Patrun, pat map[string]string, data interface{}) bool {
+  if m.count > 0 {
+    m.count--
+  }
+
+  return m.count == 0
+}
+
+//Return a Customiser that applies each of the customisers in order. Changes
+//a customiser makes to the pattern are seen by the ones after it. Find passes
+//the data through each modifier in the same order, and Remove asks every
+//modifier and only removes the pattern if they all agree.
+func Chain(customisers ...patrun.Customiser) patrun.Customiser {
+  return chain(customisers)
+}
+
+type chain []patrun.Customiser
+
+type chainModifier []patrun.Modifiers
+
+func (c chain) Add(pm *patrun.Patrun, pat map[string]string, data interface{}) patrun.Modifiers {
+  var mods chainModifier
+
+  for _, customiser := range c {
+    if m := customiser.Add(pm, pat, data); m != nil {
+      mods = append(mods, m)
+    }
+  }
+
+  if len(mods) == 0 {
+    return nil
+  }
+
+  return mods
+}
+
+func (m chainModifier) Find(pm *patrun.Patrun, pat map[string]string, data interface{}) interface{} {
+  for _, mod := range m {
+    data = mod.Find(pm, pat, data)
+  }
+
+  return data
+}
+
+//the data of the first modifier that keeps its own
+func (m chainModifier) Data() interface{} {
+  for _, mod := range m {
+    if d, ok := mod.(patrun.ModifierData); ok {
+      return d.Data()
+    }
+  }
+  return nil
+}
+
+func (m chainModifier) CloneModifier() patrun.Modifiers {
+  var mods = make(chainModifier, len(m))
+
+  for k, mod := range m {
+    mods[k] = mod
+    if c, ok := mod.(patrun.ModifierCloner); ok {
+      mods[k] = c.CloneModifier()
+    }
+  }
+
+  return mods
+}
+
+func (m chainModifier) Remove(pm *patrun.Patrun, pat map[string]string, data interface{}) bool {
+  var ok = true
+
+  for _, mod := range m {
+    ok = mod.Remove(pm, pat, data) && ok
+  }
+
+  return ok
+}
+
+//return the modifier of type T stored against exactly this pattern, looking inside chains
+func priorModifier[T patrun.Modifiers](pm *patrun.Patrun, pat map[string]string) (T, bool) {
+  var zero T
+
+  if item, found := pm.Lookup(pat); found {
+    return findModifier[T](item.Modifier)
+  }
+
+  return zero, false
+}
+
+func findModifier[T patrun.Modifiers](m patrun.Modifiers) (T, bool) {
+  if mods, ok := m.(chainModifier); ok {
+    for _, mod := range mods {
+      if found, ok := findModifier[T](mod); ok {
+        return found, true
+      }
+    }
+  }
+
+  found, ok := m.(T)
+
+  return found, ok
+}
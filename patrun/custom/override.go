@@ -0,0 +1,67 @@
+package custom
+
+import (
+  "github.com/colmharte/patrun-golang/patrun"
+)
+
+//Overrider is data for Override that wraps the prior data for the pattern.
+//It is called once when added with the data Find returned for the pattern
+//just before, nil if there was none, and returns the data to store.
+type Overrider func(prior interface{}) interface{}
+
+//Override keeps a chain of data for each pattern instead of replacing it.
+//Adding an Overrider lets the new data build on the prior data, for example a
+//handler that audits calls and then calls the handler it replaced. The prior
+//data is the pattern's own previous data, or the data of the less specific
+//pattern that matched it when this is the first time it is added. Remove pops
+//back to the prior data and only removes the pattern when the chain is empty.
+type Override struct {}
+
+type overrideModifier struct {
+  chain []interface{}
+}
+
+func (c Override) Add(pm *patrun.Patrun, pat map[string]string, data interface{}) patrun.Modifiers {
+  var chain []interface{}
+  var prior interface{}
+
+  if m, ok := priorModifier[*overrideModifier](pm, pat); ok && len(m.chain) > 0 {
+    chain = append(chain, m.chain...)
+    prior = chain[len(chain) - 1]
+  } else {
+    prior = pm.Find(pat)
+  }
+
+  if f, ok := data.(Overrider); ok {
+    data = f(prior)
+  }
+
+  return &overrideModifier{append(chain, data)}
+}
+
+func (m *overrideModifier) Find(pm *patrun.Patrun, pat map[string]string, data interface{}) interface{} {
+  if data == nil || len(m.chain) == 0 {
+    return nil
+  }
+
+  return m.chain[len(m.chain) - 1]
+}
+
+func (m *overrideModifier) Data() interface{} {
+  if len(m.chain) == 0 {
+    return nil
+  }
+  return m.chain[len(m.chain) - 1]
+}
+
+func (m *overrideModifier) CloneModifier() patrun.Modifiers {
+  return &overrideModifier{append([]interface{}(nil), m.chain...)}
+}
+
+func (m *overrideModifier) Remove(pm *patrun.Patrun, pat map[string]string, data interface{}) bool {
+  if len(m.chain) > 0 {
+    m.chain = m.chain[:len(m.chain) - 1]
+  }
+
+  return len(m.chain) == 0
+}
@@ -0,0 +1,142 @@
+package patrun
+
+import (
+  "fmt"
+  "reflect"
+  "slices"
+  "strings"
+)
+
+//Returned by Diff to describe a pattern whose data differs between the two matchers
+type DiffChange struct {
+  Match map[string]string
+  Old interface{}
+  New interface{}
+}
+
+//Returned by Diff to describe the difference between two matchers. Each list is in List order.
+type DiffReport struct {
+  //Patterns only in the new matcher
+  Added []Pattern
+  //Patterns only in the old matcher
+  Removed []Pattern
+  //Patterns in both matchers with different data
+  Changed []DiffChange
+}
+
+//Return the patterns added, removed and changed going from matcher a to
+//matcher b. Data is compared with reflect.DeepEqual, except for functions
+//which are equal when they share the same code. Closures made from the same
+//function literal are therefore equal, use DiffWith to tell them apart.
+func Diff(a, b *Patrun) DiffReport {
+  return DiffWith(a, b, nil)
+}
+
+//Same as Diff but data is compared with the custom function, nil uses the default comparison
+func DiffWith(a, b *Patrun, equal func(x, y interface{}) bool) DiffReport {
+  var report DiffReport
+
+  if equal == nil {
+    equal = dataEqual
+  }
+
+  for item := range a.All() {
+    other, found := b.lookup(item.Match)
+
+    if !found {
+      report.Removed = append(report.Removed, item)
+    } else if !equal(item.Data, other.Data) {
+      report.Changed = append(report.Changed, DiffChange{item.Match, item.Data, other.Data})
+    }
+  }
+
+  for item := range b.All() {
+    if _, found := a.lookup(item.Match); !found {
+      report.Added = append(report.Added, item)
+    }
+  }
+
+  return report
+}
+
+//Return true if there are no differences
+func (d DiffReport) Empty() bool {
+  return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+//Generate a unified diff style representation of the differences, in List order
+func (d DiffReport) String() string {
+  return d.ToString(formatData)
+}
+
+//Same as String but alows you to specify a custom formatting function for the data
+func (d DiffReport) ToString(custom func(data interface{}) string) string {
+  return d.ToUnified("old", "new", custom)
+}
+
+//Same as ToString but with the names used in the --- and +++ header lines
+func (d DiffReport) ToUnified(oldName string, newName string, custom func(data interface{}) string) string {
+  if d.Empty() {
+    return ""
+  }
+
+  type line struct {
+    match map[string]string
+    text string
+  }
+
+  var lines []line
+
+  for k := range d.Removed {
+    v := d.Removed[k]
+    lines = append(lines, line{v.Match, fmt.Sprintf("-%v -> <%v>", formatMatch(v.Match), custom(v.Data))})
+  }
+  for k := range d.Added {
+    v := d.Added[k]
+    lines = append(lines, line{v.Match, fmt.Sprintf("+%v -> <%v>", formatMatch(v.Match), custom(v.Data))})
+  }
+  for k := range d.Changed {
+    v := d.Changed[k]
+    lines = append(lines, line{v.Match, fmt.Sprintf("-%v -> <%v>\n+%v -> <%v>", formatMatch(v.Match), custom(v.Old), formatMatch(v.Match), custom(v.New))})
+  }
+
+  slices.SortStableFunc(lines, func(a, b line) int {
+    return comparePatterns(a.match, b.match)
+  })
+
+  var data = []string{"--- " + oldName, "+++ " + newName}
+
+  for k := range lines {
+    data = append(data, lines[k].text)
+  }
+
+  return strings.Join(data, "\n")
+}
+
+//order patterns the same way List does, by comparing their sorted key/value paths
+func comparePatterns(a, b map[string]string) int {
+  return slices.Compare(patternPath(a), patternPath(b))
+}
+
+func patternPath(pat map[string]string) []string {
+  var path []string
+  var keys = sortKeys(pat)
+
+  for k := range keys {
+    path = append(path, keys[k], pat[keys[k]])
+  }
+
+  return path
+}
+
+//default data comparison, functions can't be compared with DeepEqual so compare their code pointers
+func dataEqual(x, y interface{}) bool {
+  vx := reflect.ValueOf(x)
+  vy := reflect.ValueOf(y)
+
+  if vx.Kind() == reflect.Func && vy.Kind() == reflect.Func {
+    return vx.Type() == vy.Type() && vx.Pointer() == vy.Pointer()
+  }
+
+  return reflect.DeepEqual(x, y)
+}
@@ -0,0 +1,283 @@
+package dispatch
+
+import (
+  "context"
+  "fmt"
+  "sort"
+  "strings"
+  "sync"
+)
+
+//What ActAsync does when a pattern's queue is full
+type Backpressure int
+
+const (
+  //Wait for space in the queue, or until the context ends
+  BackpressureBlock Backpressure = iota
+  //Drop the message, its Future completes with ErrDropped
+  BackpressureDrop
+  //Refuse the message, ActAsync returns ErrQueueFull
+  BackpressureError
+)
+
+//The size of a queue when PoolOptions.QueueSize is 0
+const DefaultQueueSize = 64
+
+//PoolOptions configures the workers and queue ActAsync uses for a pattern
+type PoolOptions struct {
+  //The number of messages handled at once, 0 is the same as 1
+  Workers int
+  //The number of messages that can wait for a worker, 0 uses DefaultQueueSize
+  QueueSize int
+  Backpressure Backpressure
+}
+
+//Future is the pending reply to a message sent with ActAsync
+type Future struct {
+  done chan struct{}
+  reply interface{}
+  err error
+}
+
+//Return a channel that is closed when the reply is ready
+func (f *Future) Done() <-chan struct{} {
+  return f.done
+}
+
+//Wait for the reply, or return the context's error if it ends first
+func (f *Future) Wait(ctx context.Context) (interface{}, error) {
+  select {
+  case <-f.done:
+    return f.reply, f.err
+  case <-ctx.Done():
+    return nil, ctx.Err()
+  }
+}
+
+func (f *Future) resolve(reply interface{}, err error) {
+  f.reply, f.err = reply, err
+  close(f.done)
+}
+
+type job struct {
+  ctx context.Context
+  handler Handler
+  msg map[string]interface{}
+  future *Future
+}
+
+type pool struct {
+  queue chan job
+  backpressure Backpressure
+}
+
+//the state of ActAsync, created on first use
+type asyncState struct {
+  mu sync.Mutex
+  pools map[string]*pool
+  options map[string]PoolOptions
+  closed bool
+  closing chan struct{}
+  stop chan struct{}
+  senders sync.WaitGroup
+  workers sync.WaitGroup
+}
+
+func (a *asyncState) init() {
+  if a.pools == nil {
+    a.pools = map[string]*pool{}
+    a.options = map[string]PoolOptions{}
+    a.closing = make(chan struct{})
+    a.stop = make(chan struct{})
+  }
+}
+
+//Use a worker pool with these options for messages handled by the pattern,
+//instead of the Pool field. It must be called before the first message for
+//the pattern is sent with ActAsync.
+func (d *Dispatcher) SetPool(pat map[string]string, options PoolOptions) error {
+  a := &d.async
+
+  a.mu.Lock()
+  defer a.mu.Unlock()
+
+  a.init()
+
+  key := patternKey(pat)
+  if _, ok := a.pools[key]; ok {
+    return fmt.Errorf("dispatch: the pool for %v has already started", formatSubject(pat))
+  }
+
+  a.options[key] = options
+
+  return nil
+}
+
+//Queue the message for the handler that Act would call and return a Future
+//for the reply. Each pattern has its own queue and workers, created when the
+//first message for it arrives. The handler and its middleware run on a
+//worker with the same panic recovery and Timeout as Act, using ctx, so
+//cancelling ctx also cancels the queued message. A full queue is handled
+//according to the pool's Backpressure, and ErrClosed is returned after
+//Shutdown. A message with no handler returns the *NoHandlerError straight away.
+func (d *Dispatcher) ActAsync(ctx context.Context, msg map[string]interface{}) (*Future, error) {
+  handler, pat, err := d.lookup(ctx, msg, true)
+  if err != nil {
+    return nil, err
+  }
+
+  a := &d.async
+
+  a.mu.Lock()
+  if a.closed {
+    a.mu.Unlock()
+    return nil, ErrClosed
+  }
+  a.senders.Add(1)
+  p := d.pool(pat)
+  a.mu.Unlock()
+
+  defer a.senders.Done()
+
+  j := job{ctx, handler, msg, &Future{done: make(chan struct{})}}
+
+  switch p.backpressure {
+  case BackpressureDrop:
+    select {
+    case p.queue <- j:
+    default:
+      j.future.resolve(nil, ErrDropped)
+    }
+
+  case BackpressureError:
+    select {
+    case p.queue <- j:
+    default:
+      return nil, ErrQueueFull
+    }
+
+  default:
+    select {
+    case p.queue <- j:
+    case <-ctx.Done():
+      return nil, ctx.Err()
+    case <-a.closing:
+      return nil, ErrClosed
+    }
+  }
+
+  return j.future, nil
+}
+
+//Stop accepting messages with ActAsync and wait for the queued and running
+//messages to be handled. If ctx ends first its error is returned and the
+//remaining messages are still handled in the background.
+func (d *Dispatcher) Shutdown(ctx context.Context) error {
+  a := &d.async
+
+  a.mu.Lock()
+  a.init()
+  if !a.closed {
+    a.closed = true
+    close(a.closing)
+  }
+  a.mu.Unlock()
+
+  done := make(chan struct{})
+  go func() {
+    //once every sender has finished nothing more can be queued so the workers can drain and stop
+    a.senders.Wait()
+    a.mu.Lock()
+    select {
+    case <-a.stop:
+    default:
+      close(a.stop)
+    }
+    a.mu.Unlock()
+    a.workers.Wait()
+    close(done)
+  }()
+
+  select {
+  case <-done:
+    return nil
+  case <-ctx.Done():
+    return ctx.Err()
+  }
+}
+
+//return the pool for the pattern, starting it if needed. Called with the async lock held.
+func (d *Dispatcher) pool(pat map[string]string) *pool {
+  a := &d.async
+  a.init()
+
+  key := patternKey(pat)
+  if p, ok := a.pools[key]; ok {
+    return p
+  }
+
+  options, ok := a.options[key]
+  if !ok {
+    options = d.Pool
+  }
+
+  workers := max(options.Workers, 1)
+  size := options.QueueSize
+  if size <= 0 {
+    size = DefaultQueueSize
+  }
+
+  p := &pool{make(chan job, size), options.Backpressure}
+  a.pools[key] = p
+
+  a.workers.Add(workers)
+  for k := 0; k < workers; k++ {
+    go d.work(p)
+  }
+
+  return p
+}
+
+func (d *Dispatcher) work(p *pool) {
+  defer d.async.workers.Done()
+
+  for {
+    select {
+    case j := <-p.queue:
+      d.run(j)
+    case <-d.async.stop:
+      for {
+        select {
+        case j := <-p.queue:
+          d.run(j)
+        default:
+          return
+        }
+      }
+    }
+  }
+}
+
+func (d *Dispatcher) run(j job) {
+  ctx := j.ctx
+
+  if d.Timeout > 0 {
+    var cancel context.CancelFunc
+    ctx, cancel = context.WithTimeout(ctx, d.Timeout)
+    defer cancel()
+  }
+
+  j.future.resolve(call(ctx, j.handler, j.msg))
+}
+
+//return a string that uniquely identifies the properties of a pattern
+func patternKey(pat map[string]string) string {
+  var points []string
+
+  for k, v := range pat {
+    points = append(points, k + "\x00" + v)
+  }
+  sort.Strings(points)
+
+  return strings.Join(points, "\x00")
+}
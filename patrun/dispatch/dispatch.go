@@ -0,0 +1,241 @@
+// Package dispatch routes messages to handlers using a patrun matcher.
+//
+// Handlers are added against a pattern and a message is acted on by finding
+// the handler whose pattern best matches the message's properties:
+//
+//  d := &dispatch.Dispatcher{Timeout: time.Second}
+//
+//  d.AddString("role:math,cmd:sum", func(ctx context.Context, msg map[string]interface{}) (interface{}, error) {
+//    return msg["x"].(float64) + msg["y"].(float64), nil
+//  })
+//
+//  sum, err := d.Act(ctx, map[string]interface{}{"role": "math", "cmd": "sum", "x": 1.0, "y": 2.0})
+package dispatch
+
+import (
+  "context"
+  "errors"
+  "fmt"
+  "runtime/debug"
+  "sort"
+  "strconv"
+  "strings"
+  "sync"
+  "time"
+
+  "github.com/colmharte/patrun-golang/patrun"
+)
+
+//Handler acts on a message and returns the reply.
+type Handler func(ctx context.Context, msg map[string]interface{}) (interface{}, error)
+
+//Middleware wraps every handler called by Act, the first registered is the outermost.
+type Middleware func(next Handler) Handler
+
+//Dispatcher routes messages to the handler with the best matching pattern.
+//The zero value is ready to use and a Dispatcher is safe for concurrent use.
+type Dispatcher struct {
+  //The matcher holding the handlers. Set it before adding handlers to use a
+  //Schema, Customiser or Find middleware, a new matcher is created if nil.
+  Patrun *patrun.Patrun
+  //The longest a handler may run before Act gives up with context.DeadlineExceeded, 0 for no limit
+  Timeout time.Duration
+
+  //The worker pool used by ActAsync for patterns without their own from SetPool
+  Pool PoolOptions
+
+  mu sync.RWMutex
+  middleware []Middleware
+
+  async asyncState
+}
+
+//Add a handler for the pattern. The error is from patrun AddE, for example
+//when the pattern is rejected by the matcher's Schema.
+func (d *Dispatcher) Add(pat map[string]string, handler Handler) error {
+  if handler == nil {
+    return fmt.Errorf("%w: %v: nil handler", patrun.ErrInvalidPattern, formatSubject(pat))
+  }
+
+  d.mu.Lock()
+  defer d.mu.Unlock()
+
+  if d.Patrun == nil {
+    d.Patrun = &patrun.Patrun{}
+  }
+
+  return d.Patrun.AddE(pat, handler)
+}
+
+//Same as Add but with the pattern in string notation, parsed with patrun.ParsePattern
+func (d *Dispatcher) AddString(pat string, handler Handler) error {
+  items, err := patrun.ParsePattern(pat)
+  if err != nil {
+    return err
+  }
+
+  return d.Add(items, handler)
+}
+
+//Remove the handler for the pattern, the error wraps patrun.ErrNotFound if there isn't one.
+func (d *Dispatcher) Remove(pat map[string]string) error {
+  d.mu.Lock()
+  defer d.mu.Unlock()
+
+  if d.Patrun == nil {
+    return fmt.Errorf("%w: %v", patrun.ErrNotFound, formatSubject(pat))
+  }
+
+  return d.Patrun.RemoveE(pat)
+}
+
+//Register middleware around every handler called by Act.
+func (d *Dispatcher) Use(middleware ...Middleware) *Dispatcher {
+  d.mu.Lock()
+  defer d.mu.Unlock()
+
+  d.middleware = append(d.middleware, middleware...)
+
+  return d
+}
+
+//Find the handler for the message and call it through the middleware. The
+//handler is found with FindContext on the message's Subject. When no pattern
+//matches the error is a *NoHandlerError, a panic in the handler or middleware
+//is returned as a *PanicError, and when the Timeout or the context ends first
+//the context's error is returned without waiting for the handler.
+func (d *Dispatcher) Act(ctx context.Context, msg map[string]interface{}) (interface{}, error) {
+  handler, err := d.Lookup(ctx, msg)
+  if err != nil {
+    return nil, err
+  }
+
+  if d.Timeout > 0 {
+    var cancel context.CancelFunc
+    ctx, cancel = context.WithTimeout(ctx, d.Timeout)
+    defer cancel()
+  }
+
+  return call(ctx, handler, msg)
+}
+
+//Return the handler Act would call for the message, wrapped in the middleware.
+func (d *Dispatcher) Lookup(ctx context.Context, msg map[string]interface{}) (Handler, error) {
+  handler, _, err := d.lookup(ctx, msg, false)
+
+  return handler, err
+}
+
+//find the handler and, when asked, the pattern it was added with
+func (d *Dispatcher) lookup(ctx context.Context, msg map[string]interface{}, withPattern bool) (Handler, map[string]string, error) {
+  d.mu.RLock()
+  defer d.mu.RUnlock()
+
+  if d.Patrun == nil {
+    return nil, nil, &NoHandlerError{msg, patrun.ErrNotFound}
+  }
+
+  subject := Subject(msg)
+
+  data, err := d.Patrun.FindContext(ctx, subject)
+  if errors.Is(err, patrun.ErrNotFound) {
+    return nil, nil, &NoHandlerError{msg, err}
+  }
+  if err != nil {
+    return nil, nil, err
+  }
+
+  var pat map[string]string
+  if withPattern {
+    pat = d.Patrun.Explain(subject).Match
+  }
+
+  handler, ok := data.(Handler)
+  if !ok {
+    return nil, nil, fmt.Errorf("dispatch: %v: data is a %T not a Handler", formatSubject(subject), data)
+  }
+
+  for k := len(d.middleware) - 1; k >= 0; k-- {
+    handler = d.middleware[k](handler)
+  }
+
+  return handler, pat, nil
+}
+
+//call the handler, recovering panics, and stop waiting for it when the context ends
+func call(ctx context.Context, handler Handler, msg map[string]interface{}) (interface{}, error) {
+  type result struct {
+    reply interface{}
+    err error
+  }
+
+  run := func() (res result) {
+    defer func() {
+      if v := recover(); v != nil {
+        res = result{nil, &PanicError{v, debug.Stack()}}
+      }
+    }()
+
+    reply, err := handler(ctx, msg)
+    return result{reply, err}
+  }
+
+  if ctx.Done() == nil {
+    res := run()
+    return res.reply, res.err
+  }
+
+  if err := ctx.Err(); err != nil {
+    return nil, err
+  }
+
+  done := make(chan result, 1)
+  go func() {
+    done <- run()
+  }()
+
+  select {
+  case res := <-done:
+    return res.reply, res.err
+  case <-ctx.Done():
+    return nil, ctx.Err()
+  }
+}
+
+//Return the properties of the message used to find its handler. Strings are
+//used as they are, and numbers and booleans are formatted the way
+//strconv does, so a float64 of 1 from JSON matches a pattern value of 1.
+//Other properties are ignored.
+func Subject(msg map[string]interface{}) map[string]string {
+  var subject = make(map[string]string, len(msg))
+
+  for k, v := range msg {
+    switch val := v.(type) {
+    case string:
+      subject[k] = val
+    case bool:
+      subject[k] = strconv.FormatBool(val)
+    case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+      subject[k] = fmt.Sprint(val)
+    case float32:
+      subject[k] = strconv.FormatFloat(float64(val), 'f', -1, 32)
+    case float64:
+      subject[k] = strconv.FormatFloat(val, 'f', -1, 64)
+    case fmt.Stringer:
+      subject[k] = val.String()
+    }
+  }
+
+  return subject
+}
+
+func formatSubject(subject map[string]string) string {
+  var points []string
+
+  for k, v := range subject {
+    points = append(points, k + ":" + v)
+  }
+  sort.Strings(points)
+
+  return strings.Join(points, ", ")
+}
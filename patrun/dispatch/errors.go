@@ -0,0 +1,50 @@
+package dispatch
+
+import (
+  "errors"
+  "fmt"
+)
+
+//Returned, wrapped in a *NoHandlerError, by Act when no pattern matches the message.
+var ErrNoHandler = errors.New("dispatch: no handler")
+
+//Returned by Act when no pattern matches the message. It matches both
+//ErrNoHandler and patrun.ErrNotFound with errors.Is.
+type NoHandlerError struct {
+  //The message that couldn't be dispatched
+  Message map[string]interface{}
+  err error
+}
+
+func (e *NoHandlerError) Error() string {
+  return fmt.Sprintf("dispatch: no handler for %v", formatSubject(Subject(e.Message)))
+}
+
+func (e *NoHandlerError) Is(target error) bool {
+  return target == ErrNoHandler
+}
+
+func (e *NoHandlerError) Unwrap() error {
+  return e.err
+}
+
+//Returned by Act when the handler, or middleware around it, panics.
+type PanicError struct {
+  //The value passed to panic
+  Value interface{}
+  //The stack of the goroutine that panicked
+  Stack []byte
+}
+
+func (e *PanicError) Error() string {
+  return fmt.Sprintf("dispatch: handler panicked: %v", e.Value)
+}
+
+//Returned by ActAsync when the pattern's queue is full and its pool uses BackpressureError.
+var ErrQueueFull = errors.New("dispatch: queue full")
+
+//Set as the error of the Future returned by ActAsync when the pattern's queue is full and its pool uses BackpressureDrop.
+var ErrDropped = errors.New("dispatch: message dropped")
+
+//Returned by ActAsync once Shutdown has been called.
+var ErrClosed = errors.New("dispatch: dispatcher shut down")
@@ -0,0 +1,186 @@
+package dispatch
+
+import (
+  "bytes"
+  "context"
+  "encoding/json"
+  "errors"
+  "fmt"
+  "io"
+  "net/http"
+
+  "github.com/colmharte/patrun-golang/patrun"
+)
+
+//The kinds of error sent by Server
+const (
+  //No handler matched the message
+  KindNoHandler = "no-handler"
+  //The message or its pattern was rejected, for example by the matcher's Schema
+  KindInvalid = "invalid"
+  //The handler didn't reply before the dispatcher's Timeout
+  KindTimeout = "timeout"
+  //The handler panicked
+  KindPanic = "panic"
+  //The handler returned an error, or the request failed
+  KindError = "error"
+)
+
+//The largest request Server accepts when MaxBytes is 0
+const DefaultMaxBytes = 1 << 20
+
+//Server exposes a Dispatcher over HTTP. Each request is a POST with a JSON
+//object as the body, which is passed to Act as the message. The response is
+//a JSON object with either the reply, {"reply": ...}, or the error,
+//{"reply": null, "error": {"kind": ..., "message": ...}}.
+type Server struct {
+  Dispatcher *Dispatcher
+  //The largest request body accepted, 0 uses DefaultMaxBytes
+  MaxBytes int64
+}
+
+type response struct {
+  Reply interface{} `json:"reply"`
+  Error *wireError `json:"error,omitempty"`
+}
+
+type wireError struct {
+  Kind string `json:"kind"`
+  Message string `json:"message"`
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+  if r.Method != http.MethodPost {
+    w.Header().Set("Allow", http.MethodPost)
+    writeResponse(w, http.StatusMethodNotAllowed, response{Error: &wireError{KindError, "dispatch: only POST is supported"}})
+    return
+  }
+
+  limit := s.MaxBytes
+  if limit <= 0 {
+    limit = DefaultMaxBytes
+  }
+
+  var msg map[string]interface{}
+  if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, limit)).Decode(&msg); err != nil || msg == nil {
+    writeResponse(w, http.StatusBadRequest, response{Error: &wireError{KindInvalid, fmt.Sprintf("dispatch: the body must be a JSON object: %v", err)}})
+    return
+  }
+
+  reply, err := s.Dispatcher.Act(r.Context(), msg)
+  if err != nil {
+    status, kind := errorKind(err)
+    writeResponse(w, status, response{Error: &wireError{kind, err.Error()}})
+    return
+  }
+
+  writeResponse(w, http.StatusOK, response{Reply: reply})
+}
+
+func errorKind(err error) (int, string) {
+  var panicErr *PanicError
+
+  switch {
+  case errors.Is(err, ErrNoHandler):
+    return http.StatusNotFound, KindNoHandler
+  case errors.Is(err, patrun.ErrInvalidPattern):
+    return http.StatusBadRequest, KindInvalid
+  case errors.Is(err, context.DeadlineExceeded):
+    return http.StatusGatewayTimeout, KindTimeout
+  case errors.As(err, &panicErr):
+    return http.StatusInternalServerError, KindPanic
+  }
+
+  return http.StatusInternalServerError, KindError
+}
+
+func writeResponse(w http.ResponseWriter, status int, res response) {
+  body, err := json.Marshal(res)
+  if err != nil {
+    status = http.StatusInternalServerError
+    body, _ = json.Marshal(response{Error: &wireError{KindError, fmt.Sprintf("dispatch: the reply can't be encoded: %v", err)}})
+  }
+
+  w.Header().Set("Content-Type", "application/json")
+  w.WriteHeader(status)
+  w.Write(append(body, '\n'))
+}
+
+//Returned by Client when the server reports an error. It matches
+//ErrNoHandler, patrun.ErrInvalidPattern and context.DeadlineExceeded with
+//errors.Is according to its Kind.
+type RemoteError struct {
+  //One of the Kind constants
+  Kind string
+  Message string
+  //The HTTP status of the response
+  StatusCode int
+}
+
+func (e *RemoteError) Error() string {
+  return e.Message
+}
+
+func (e *RemoteError) Is(target error) bool {
+  switch e.Kind {
+  case KindNoHandler:
+    return target == ErrNoHandler
+  case KindInvalid:
+    return target == patrun.ErrInvalidPattern
+  case KindTimeout:
+    return target == context.DeadlineExceeded
+  }
+  return false
+}
+
+//Client sends messages to a Server
+type Client struct {
+  //The address of the Server, eg http://localhost:8080/act
+  URL string
+  //The client used to send requests, nil uses http.DefaultClient
+  HTTPClient *http.Client
+}
+
+//Send the message to the server and return the reply. Numbers in the reply
+//are decoded as float64, the same as encoding/json. Errors reported by the
+//server are returned as a *RemoteError.
+func (c *Client) Act(ctx context.Context, msg map[string]interface{}) (interface{}, error) {
+  body, err := json.Marshal(msg)
+  if err != nil {
+    return nil, fmt.Errorf("dispatch: the message can't be encoded: %w", err)
+  }
+
+  req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.URL, bytes.NewReader(body))
+  if err != nil {
+    return nil, err
+  }
+  req.Header.Set("Content-Type", "application/json")
+
+  client := c.HTTPClient
+  if client == nil {
+    client = http.DefaultClient
+  }
+
+  resp, err := client.Do(req)
+  if err != nil {
+    return nil, err
+  }
+  defer resp.Body.Close()
+
+  var res response
+  if err := json.NewDecoder(io.LimitReader(resp.Body, DefaultMaxBytes)).Decode(&res); err != nil {
+    return nil, fmt.Errorf("dispatch: invalid response from %v, status %v: %w", c.URL, resp.StatusCode, err)
+  }
+
+  if res.Error != nil {
+    return nil, &RemoteError{res.Error.Kind, res.Error.Message, resp.StatusCode}
+  }
+
+  return res.Reply, nil
+}
+
+//Return a Handler that forwards messages to another process with the client,
+//so patterns can be routed to a remote Server.
+func Remote(client *Client) Handler {
+  return client.Act
+}
@@ -0,0 +1,103 @@
+package patrun
+
+import (
+  "context"
+  "errors"
+  "fmt"
+  "strings"
+)
+
+//Returned, possibly wrapped, when the same pattern is registered with different data and that isn't allowed.
+var ErrConflict = errors.New("patrun: conflicting pattern")
+
+//Returned, possibly wrapped, by ReadFrom and ReadSnapshot when the input isn't a valid snapshot.
+var ErrSnapshotFormat = errors.New("patrun: invalid snapshot")
+
+//Returned, possibly wrapped, by ReadFrom and ReadSnapshot when the snapshot was written by an unsupported version.
+var ErrSnapshotVersion = errors.New("patrun: unsupported snapshot version")
+
+//Returned, possibly wrapped, by ReadFrom and ReadSnapshot when the snapshot is corrupt.
+var ErrSnapshotChecksum = errors.New("patrun: snapshot checksum mismatch")
+
+//Returned, possibly wrapped, by ReadFrom and ReadSnapshot when the snapshot has patterns with modifiers and there is no Customiser to recreate them.
+var ErrSnapshotModifiers = errors.New("patrun: snapshot needs a Customiser")
+
+//Returned, possibly wrapped, by the error returning methods when there is no matching pattern.
+var ErrNotFound = errors.New("patrun: pattern not found")
+
+//Returned, possibly wrapped, when a pattern or subject is malformed or rejected by the Schema.
+var ErrInvalidPattern = errors.New("patrun: invalid pattern")
+
+//Customisers can also implement CustomiserE to reject a pattern. AddE uses it
+//in place of Add, the other methods still call Add.
+type CustomiserE interface {
+  AddE(pm *Patrun, pat map[string]string, data interface{}) (Modifiers, error)
+}
+
+//Modifiers can also implement ModifiersE to report a failure. FindE,
+//FindExactE and RemoveE use it in place of Find and Remove.
+type ModifiersE interface {
+  FindE(pm *Patrun, pat map[string]string, data interface{}) (interface{}, error)
+  RemoveE(pm *Patrun, pat map[string]string, data interface{}) (bool, error)
+}
+
+//Same as Add but returns an error instead of quietly ignoring a pattern. The
+//error wraps ErrInvalidPattern when the data is nil or the Schema rejects the
+//pattern, otherwise it is the error returned by a CustomiserE.
+func (p *Patrun) AddE(pat map[string]string, data interface{}) error {
+  return p.AddContext(context.Background(), pat, data)
+}
+
+//Same as Remove but returns an error wrapping ErrNotFound when the pattern
+//isn't registered, or the error returned by a ModifiersE. A modifier can still
+//decline the removal without an error.
+func (p *Patrun) RemoveE(pat map[string]string) error {
+  return p.RemoveContext(context.Background(), pat)
+}
+
+//Same as Find but returns an error wrapping ErrNotFound instead of nil when
+//nothing matches, the SchemaError when the Schema rejects the subject, or the
+//error returned by a ModifiersE.
+func (p *Patrun) FindE(pat map[string]string) (interface{}, error) {
+  return p.FindContext(context.Background(), pat)
+}
+
+//Same as FindE but only matches where all properties match will be returned.
+func (p *Patrun) FindExactE(pat map[string]string) (interface{}, error) {
+  return p.FindExactContext(context.Background(), pat)
+}
+
+//Convert a pattern in simple string notation to a map. Unlike AddString, which
+//skips malformed items, an error wrapping ErrInvalidPattern is returned. Each
+//comma separated item must be a key and a value separated by a single colon,
+//and keys can't be empty or repeated.
+func ParsePattern(pat string) (map[string]string, error) {
+  var items = map[string]string{}
+
+  for _, item := range strings.Split(pat, ",") {
+    item = strings.TrimSpace(item)
+    if item == "" {
+      continue
+    }
+
+    key, val, found := strings.Cut(item, ":")
+    key = strings.TrimSpace(key)
+
+    switch {
+    case !found:
+      return nil, fmt.Errorf("%w: %q has no value", ErrInvalidPattern, item)
+    case strings.Contains(val, ":"):
+      return nil, fmt.Errorf("%w: %q has more than one colon", ErrInvalidPattern, item)
+    case key == "":
+      return nil, fmt.Errorf("%w: %q has no key", ErrInvalidPattern, item)
+    }
+
+    if _, ok := items[key]; ok {
+      return nil, fmt.Errorf("%w: key %q is repeated", ErrInvalidPattern, key)
+    }
+
+    items[key] = strings.TrimSpace(val)
+  }
+
+  return items, nil
+}
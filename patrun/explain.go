@@ -0,0 +1,83 @@
+package patrun
+
+import (
+  "context"
+)
+
+//Returned by Explain to describe how a subject was resolved
+type Explanation struct {
+  //The registered pattern that supplied the data, nil if nothing matched
+  Match map[string]string
+  //The result of Find, including any modifier
+  Data interface{}
+}
+
+//Return the result of Find together with the pattern that was responsible for
+//it. When middleware is registered the subject goes through the chain like
+//Find, and Match is the pattern the end of the chain found for the subject it
+//was given, nil if the middleware answered without reaching the tree.
+func (p *Patrun) Explain(pat map[string]string) Explanation {
+  return p.explainItem(pat, false)
+}
+
+//Same as Explain but using simple string notation
+func (p *Patrun) ExplainString(pat string) Explanation {
+  return p.Explain(createMap(pat))
+}
+
+//Same as Explain but for FindExact
+func (p *Patrun) ExplainExact(pat map[string]string) Explanation {
+  return p.explainItem(pat, true)
+}
+
+func (p *Patrun) explainItem(pat map[string]string, exact bool) Explanation {
+  var result Explanation
+
+  if p.chain != nil {
+    result.Data, _ = p.chain(context.WithValue(context.Background(), explainKey{}, &explainTrace{p, &result}), p, pat, exact)
+    return result
+  }
+
+  s := findPool.Get().(*findScratch)
+
+  s.pairs = appendMapPairs(s.pairs[:0], pat)
+  if !p.accepts(s.pairs) {
+    findPool.Put(s)
+    return result
+  }
+
+  lastData, lastModifier := p.walk(s, exact, true)
+
+  if lastData != nil {
+    result.Match = matchMap(s.match)
+  }
+
+  findPool.Put(s)
+
+  if lastModifier != nil {
+    lastData = lastModifier.Find(p, pat, lastData)
+  }
+  result.Data = lastData
+
+  return result
+}
+
+//carried in the context by Explain so the end of the middleware chain can
+//record the pattern it found
+type explainKey struct {}
+
+type explainTrace struct {
+  //only the matcher being explained records, not others the middleware calls
+  pm *Patrun
+  result *Explanation
+}
+
+func matchMap(match []pair) map[string]string {
+  var result = make(map[string]string, len(match))
+
+  for k := range match {
+    result[match[k].key] = match[k].val
+  }
+
+  return result
+}
@@ -0,0 +1,237 @@
+package patrun
+
+import (
+  "bytes"
+  "fmt"
+  "go/format"
+  "sort"
+  "strconv"
+  "strings"
+  "unicode"
+)
+
+//Options for GenerateGo
+type GenerateOptions struct {
+  //Package name of the generated file, default main
+  Package string
+  //Name of the generated function, default Match
+  Func string
+  //Go type of the data, default string
+  Type string
+  //Convert each data item to a Go expression of Type, nil handles strings, bools and numbers
+  Literal func(data interface{}) (string, error)
+}
+
+//Generate Go source for a function, func Match(subject map[string]string) (T, bool),
+//that returns the same results as Find without needing the matcher at run
+//time. The decision tree is compiled into nested switch statements. Patterns
+//with modifiers can't be generated as modifiers are run time values.
+func (p *Patrun) GenerateGo(opts GenerateOptions) ([]byte, error) {
+  if opts.Package == "" {
+    opts.Package = "main"
+  }
+  if opts.Func == "" {
+    opts.Func = "Match"
+  }
+  if opts.Type == "" {
+    opts.Type = "string"
+  }
+  if opts.Literal == nil {
+    opts.Literal = goLiteral
+  }
+
+  var prefix = string(unicode.ToLower(rune(opts.Func[0]))) + opts.Func[1:]
+
+  g := &generator{literal: opts.Literal}
+  if err := g.add(p.tree, nil); err != nil {
+    return nil, err
+  }
+
+  var b bytes.Buffer
+
+  fmt.Fprintf(&b, "// Code generated by patrun GenerateGo; DO NOT EDIT.\n\n")
+  fmt.Fprintf(&b, "package %v\n\n", opts.Package)
+  fmt.Fprintf(&b, "import \"sort\"\n\n")
+
+  fmt.Fprintf(&b, `// %[1]v returns the data for the most specific pattern matching the subject,
+// with the same results as patrun's Find. The bool is false when nothing matches.
+func %[1]v(subject map[string]string) (%[2]v, bool) {
+  keys := make([]string, 0, len(subject))
+  for k := range subject {
+    keys = append(keys, k)
+  }
+  sort.Strings(keys)
+
+  current := 0
+  lastGood := 0
+  last := -1
+  if %[3]vHasData[0] {
+    last = 0
+  }
+  var stars []int
+
+  for i := 0; i < len(keys); {
+    next := %[3]vStep(current, keys[i], subject[keys[i]])
+
+    if next >= 0 {
+      if %[3]vHasChildren[lastGood] {
+        stars = append(stars, lastGood)
+      }
+      current = next
+      lastGood = next
+      if %[3]vHasData[next] {
+        last = next
+      }
+      i++
+    } else if last < 0 && len(stars) > 0 {
+      current = stars[len(stars)-1]
+      stars = stars[:len(stars)-1]
+      lastGood = current
+    } else {
+      current = lastGood
+      i++
+    }
+  }
+
+  if last < 0 {
+    var none %[2]v
+    return none, false
+  }
+
+  return %[3]vData[last], true
+}
+
+`, opts.Func, opts.Type, prefix)
+
+  fmt.Fprintf(&b, "func %vStep(state int, key string, val string) int {\n", prefix)
+  fmt.Fprintf(&b, "switch state {\n")
+  for id, state := range g.states {
+    if len(state.keys) == 0 {
+      continue
+    }
+
+    fmt.Fprintf(&b, "case %v:\n", id)
+    fmt.Fprintf(&b, "switch key {\n")
+    for _, key := range state.keys {
+      fmt.Fprintf(&b, "case %v:\n", strconv.Quote(key))
+      fmt.Fprintf(&b, "switch val {\n")
+      for _, val := range state.values[key] {
+        fmt.Fprintf(&b, "case %v:\n return %v\n", strconv.Quote(val.value), val.state)
+      }
+      fmt.Fprintf(&b, "}\n")
+    }
+    fmt.Fprintf(&b, "}\n")
+  }
+  fmt.Fprintf(&b, "}\n\nreturn -1\n}\n\n")
+
+  fmt.Fprintf(&b, "var %vHasChildren = [...]bool{", prefix)
+  for _, state := range g.states {
+    fmt.Fprintf(&b, "%v, ", state.hasChildren)
+  }
+  fmt.Fprintf(&b, "}\n\n")
+
+  fmt.Fprintf(&b, "var %vHasData = [...]bool{", prefix)
+  for _, state := range g.states {
+    fmt.Fprintf(&b, "%v, ", state.data != "")
+  }
+  fmt.Fprintf(&b, "}\n\n")
+
+  fmt.Fprintf(&b, "var %vData = [%v]%v{\n", prefix, len(g.states), opts.Type)
+  for id, state := range g.states {
+    if state.data != "" {
+      fmt.Fprintf(&b, "%v: %v,\n", id, state.data)
+    }
+  }
+  fmt.Fprintf(&b, "}\n")
+
+  src, err := format.Source(b.Bytes())
+  if err != nil {
+    return nil, fmt.Errorf("patrun: generated code is not valid Go, check the Type and Literal options: %w", err)
+  }
+
+  return src, nil
+}
+
+//each value node, and the root, becomes a numbered state
+type generatorState struct {
+  keys []string
+  values map[string][]generatorValue
+  hasChildren bool
+  data string
+}
+
+type generatorValue struct {
+  value string
+  state int
+}
+
+type generator struct {
+  states []*generatorState
+  literal func(data interface{}) (string, error)
+}
+
+func (g *generator) add(n node, path []string) error {
+  state := &generatorState{values: map[string][]generatorValue{}, hasChildren: len(n.value) > 0}
+  g.states = append(g.states, state)
+
+  if n.modifier != nil {
+    return fmt.Errorf("patrun: pattern %v has a modifier", formatMatch(convertListToMap(path)))
+  }
+
+  if n.data != nil {
+    literal, err := g.literal(n.data)
+    if err != nil {
+      return fmt.Errorf("patrun: pattern %v: %w", formatMatch(convertListToMap(path)), err)
+    }
+    state.data = literal
+  }
+
+  var keys []string
+  for k := range n.value {
+    keys = append(keys, k)
+  }
+  sort.Strings(keys)
+
+  for _, key := range keys {
+    var values []string
+    for v := range n.value[key].value {
+      //Find treats nodes with an empty value as missing so they can never be reached
+      if v != "" {
+        values = append(values, v)
+      }
+    }
+    sort.Strings(values)
+
+    if len(values) > 0 {
+      state.keys = append(state.keys, key)
+    }
+
+    for _, val := range values {
+      state.values[key] = append(state.values[key], generatorValue{val, len(g.states)})
+
+      if err := g.add(n.value[key].value[val], append(path, key, val)); err != nil {
+        return err
+      }
+    }
+  }
+
+  return nil
+}
+
+//the default Literal option
+func goLiteral(data interface{}) (string, error) {
+  switch v := data.(type) {
+  case string:
+    return strconv.Quote(v), nil
+  case bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+    return fmt.Sprintf("%v", v), nil
+  case float32, float64:
+    s := fmt.Sprintf("%v", v)
+    if strings.ContainsAny(s, "NI") {
+      return "", fmt.Errorf("can't generate %v", s)
+    }
+    return s, nil
+  }
+
+  return "", fmt.Errorf("can't generate data of type %T, use the Literal option", data)
+}
@@ -0,0 +1,211 @@
+package patrun
+
+import (
+  "fmt"
+  "sort"
+  "strings"
+)
+
+//Options for ToDOTWith and ToMermaidWith
+type GraphOptions struct {
+  //Highlight the path to the pattern Explain finds for this subject, nil for no highlighting
+  Subject map[string]string
+  //Format the data shown on nodes, nil uses the same format as String
+  Format func(data interface{}) string
+}
+
+//the kinds of node drawn
+const (
+  graphRoot = iota
+  graphKey
+  graphValue
+)
+
+type graphNode struct {
+  id int
+  parent int
+  kind int
+  label string
+  data string
+  hasData bool
+  highlight bool
+}
+
+//Generate a Graphviz DOT representation of the decision tree. Property names
+//are drawn as ellipses, values as boxes and nodes holding data are filled.
+func (p *Patrun) ToDOT() string {
+  return p.ToDOTWith(GraphOptions{})
+}
+
+//Same as ToDOT with options for highlighting and formatting
+func (p *Patrun) ToDOTWith(opts GraphOptions) string {
+  var b strings.Builder
+
+  b.WriteString("digraph patrun {\n")
+  b.WriteString("  node [fontname=\"Helvetica\"];\n")
+
+  nodes := p.graphNodes(opts)
+
+  for _, n := range nodes {
+    var attrs []string
+
+    switch n.kind {
+    case graphRoot:
+      attrs = append(attrs, "shape=doublecircle")
+    case graphKey:
+      attrs = append(attrs, "shape=ellipse")
+    case graphValue:
+      attrs = append(attrs, "shape=box")
+    }
+
+    label := n.label
+    if n.hasData {
+      label = fmt.Sprintf("%v\n<%v>", n.label, n.data)
+      attrs = append(attrs, "style=filled", "fillcolor=\"#ffe9a8\"")
+    }
+    if n.highlight {
+      attrs = append(attrs, "color=\"#d00000\"", "penwidth=2")
+    }
+
+    fmt.Fprintf(&b, "  n%v [label=%v, %v];\n", n.id, dotQuote(label), strings.Join(attrs, ", "))
+  }
+
+  for _, n := range nodes[1:] {
+    if n.highlight {
+      fmt.Fprintf(&b, "  n%v -> n%v [color=\"#d00000\", penwidth=2];\n", n.parent, n.id)
+    } else {
+      fmt.Fprintf(&b, "  n%v -> n%v;\n", n.parent, n.id)
+    }
+  }
+
+  b.WriteString("}\n")
+
+  return b.String()
+}
+
+//Generate a Mermaid flowchart of the decision tree, drawn the same way as ToDOT
+func (p *Patrun) ToMermaid() string {
+  return p.ToMermaidWith(GraphOptions{})
+}
+
+//Same as ToMermaid with options for highlighting and formatting
+func (p *Patrun) ToMermaidWith(opts GraphOptions) string {
+  var b strings.Builder
+  var dataNodes, pathNodes, pathLinks []string
+
+  b.WriteString("flowchart TD\n")
+
+  nodes := p.graphNodes(opts)
+
+  for _, n := range nodes {
+    label := n.label
+    if n.hasData {
+      label = fmt.Sprintf("%v\n<%v>", n.label, n.data)
+      dataNodes = append(dataNodes, fmt.Sprintf("n%v", n.id))
+    }
+    if n.highlight {
+      pathNodes = append(pathNodes, fmt.Sprintf("n%v", n.id))
+    }
+
+    switch n.kind {
+    case graphRoot:
+      fmt.Fprintf(&b, "  n%v((%v))\n", n.id, mermaidQuote(label))
+    case graphKey:
+      fmt.Fprintf(&b, "  n%v([%v])\n", n.id, mermaidQuote(label))
+    case graphValue:
+      fmt.Fprintf(&b, "  n%v[%v]\n", n.id, mermaidQuote(label))
+    }
+  }
+
+  for k, n := range nodes[1:] {
+    fmt.Fprintf(&b, "  n%v --> n%v\n", n.parent, n.id)
+    if n.highlight {
+      pathLinks = append(pathLinks, fmt.Sprintf("%v", k))
+    }
+  }
+
+  b.WriteString("  classDef data fill:#ffe9a8\n")
+  b.WriteString("  classDef path stroke:#d00000,stroke-width:2px\n")
+
+  if len(dataNodes) > 0 {
+    fmt.Fprintf(&b, "  class %v data\n", strings.Join(dataNodes, ","))
+  }
+  if len(pathNodes) > 0 {
+    fmt.Fprintf(&b, "  class %v path\n", strings.Join(pathNodes, ","))
+  }
+  if len(pathLinks) > 0 {
+    fmt.Fprintf(&b, "  linkStyle %v stroke:#d00000,stroke-width:2px\n", strings.Join(pathLinks, ","))
+  }
+
+  return b.String()
+}
+
+//flatten the tree into nodes in key order, each with the id of its parent
+func (p *Patrun) graphNodes(opts GraphOptions) []graphNode {
+  var format = opts.Format
+  if format == nil {
+    format = formatData
+  }
+
+  //the path to highlight, as alternating keys and values
+  var path []string
+  if opts.Subject != nil {
+    if e := p.Explain(opts.Subject); e.Match != nil {
+      path = append([]string{""}, patternPath(e.Match)...)
+    }
+  }
+
+  var nodes []graphNode
+
+  var add func(n node, parent int, kind int, depth int, onPath bool)
+  add = func(n node, parent int, kind int, depth int, onPath bool) {
+    item := graphNode{id: len(nodes), parent: parent, kind: kind, label: n.key}
+
+    item.highlight = onPath && depth < len(path) && (depth == 0 || path[depth] == n.key)
+    if kind == graphRoot {
+      item.label = "root"
+    }
+    if n.data != nil {
+      item.hasData = true
+      item.data = format(n.data)
+    }
+
+    nodes = append(nodes, item)
+
+    var keys []string
+    for k := range n.value {
+      keys = append(keys, k)
+    }
+    sort.Strings(keys)
+
+    var childKind = graphKey
+    if kind == graphKey {
+      childKind = graphValue
+    }
+
+    for k := range keys {
+      add(n.value[keys[k]], item.id, childKind, depth + 1, item.highlight)
+    }
+  }
+
+  add(p.tree, 0, graphRoot, 0, true)
+
+  return nodes
+}
+
+func dotQuote(s string) string {
+  s = strings.ReplaceAll(s, "\\", "\\\\")
+  s = strings.ReplaceAll(s, "\"", "\\\"")
+  s = strings.ReplaceAll(s, "\n", "\\n")
+
+  return "\"" + s + "\""
+}
+
+func mermaidQuote(s string) string {
+  s = strings.ReplaceAll(s, "\"", "#quot;")
+  s = strings.ReplaceAll(s, "<", "#lt;")
+  s = strings.ReplaceAll(s, ">", "#gt;")
+  s = strings.ReplaceAll(s, "\n", "<br/>")
+
+  return "\"" + s + "\""
+}
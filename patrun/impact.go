@@ -0,0 +1,259 @@
+package patrun
+
+import (
+  "maps"
+  "slices"
+)
+
+//Returned by ImpactAnalysis to describe a subject whose result changed
+type ImpactChange struct {
+  Subject map[string]string
+  Old Explanation
+  New Explanation
+}
+
+//A set of changes that are caused by the same pattern
+type ImpactGroup struct {
+  //The responsible pattern, taken from the new matcher or from the old one if nothing matches in the new one
+  Match map[string]string
+  Changes []ImpactChange
+}
+
+//Returned by ImpactAnalysis to describe which subjects change result
+type ImpactReport struct {
+  //Number of subjects that were replayed
+  Subjects int
+  //Every subject whose result changed, in the order they were given
+  Changes []ImpactChange
+}
+
+//Replay the subjects against both matchers and report each subject where Find
+//gives a different result. Results are compared the same way as Diff. When
+//subjects is nil, subjects are built from the patterns of both matchers and
+//the patterns related to each change, see DistinguishingSubjects.
+func ImpactAnalysis(old, new *Patrun, subjects []map[string]string) ImpactReport {
+  return ImpactAnalysisWith(old, new, subjects, nil)
+}
+
+//Same as ImpactAnalysis but results are compared with the custom function, nil uses the default comparison
+func ImpactAnalysisWith(old, new *Patrun, subjects []map[string]string, equal func(x, y interface{}) bool) ImpactReport {
+  var report ImpactReport
+
+  if equal == nil {
+    equal = dataEqual
+  }
+
+  if subjects == nil {
+    subjects = candidateSubjects(old, new)
+  }
+
+  report.Subjects = len(subjects)
+
+  for k := range subjects {
+    before := old.Explain(subjects[k])
+    after := new.Explain(subjects[k])
+
+    if !equal(before.Data, after.Data) {
+      report.Changes = append(report.Changes, ImpactChange{subjects[k], before, after})
+    }
+  }
+
+  return report
+}
+
+//Return the changes grouped by the pattern responsible, in List order
+func (r ImpactReport) ByPattern() []ImpactGroup {
+  var groups []ImpactGroup
+  var index = map[string]int{}
+
+  for k := range r.Changes {
+    match := r.Changes[k].New.Match
+    if match == nil {
+      match = r.Changes[k].Old.Match
+    }
+    if match == nil {
+      match = map[string]string{}
+    }
+
+    id := patternKey(match)
+    if _, ok := index[id]; !ok {
+      index[id] = len(groups)
+      groups = append(groups, ImpactGroup{Match: match})
+    }
+
+    groups[index[id]].Changes = append(groups[index[id]].Changes, r.Changes[k])
+  }
+
+  slices.SortStableFunc(groups, func(a, b ImpactGroup) int {
+    return comparePatterns(a.Match, b.Match)
+  })
+
+  return groups
+}
+
+//Return a small set of subjects, derived from the patterns of both matchers,
+//that get a different result from each matcher. There is one subject for each
+//change of outcome, a pair of the pattern matched by the old matcher and the
+//pattern matched by the new one, and it is the one with the fewest properties.
+//The subjects tried are every pattern, each changed pattern combined with the
+//patterns that share a property with it, and each changed pattern with one
+//more property from a branch on its path, so changes that only show with the
+//properties of an unrelated pattern aren't found.
+func DistinguishingSubjects(old, new *Patrun) []map[string]string {
+  var subjects []map[string]string
+  var index = map[string]int{}
+
+  for _, change := range ImpactAnalysis(old, new, nil).Changes {
+    id := outcomeKey(change.Old.Match) + "\x01" + outcomeKey(change.New.Match)
+
+    if k, ok := index[id]; !ok {
+      index[id] = len(subjects)
+      subjects = append(subjects, change.Subject)
+    } else if len(change.Subject) < len(subjects[k]) {
+      subjects[k] = change.Subject
+    }
+  }
+
+  return subjects
+}
+
+//identify a matched pattern, keeping nothing matched apart from the root pattern
+func outcomeKey(match map[string]string) string {
+  if match == nil {
+    return "\x02"
+  }
+
+  return patternKey(match)
+}
+
+//return the subjects DistinguishingSubjects tries, without duplicates
+func candidateSubjects(old, new *Patrun) []map[string]string {
+  var subjects []map[string]string
+  var seen = map[string]bool{}
+
+  add := func(pat map[string]string) {
+    id := patternKey(pat)
+    if !seen[id] {
+      seen[id] = true
+      subjects = append(subjects, pat)
+    }
+  }
+
+  //the patterns that have each property, by their position in all
+  var all []map[string]string
+  var having = map[pair][]int{}
+
+  for _, pm := range []*Patrun{old, new} {
+    for item := range pm.All() {
+      for k, v := range item.Match {
+        having[pair{k, v}] = append(having[pair{k, v}], len(all))
+      }
+      all = append(all, item.Match)
+      add(item.Match)
+    }
+  }
+
+  d := Diff(old, new)
+
+  var changed []map[string]string
+  for k := range d.Added {
+    changed = append(changed, d.Added[k].Match)
+  }
+  for k := range d.Removed {
+    changed = append(changed, d.Removed[k].Match)
+  }
+  for k := range d.Changed {
+    changed = append(changed, d.Changed[k].Match)
+  }
+
+  for _, pat := range changed {
+    var related []int
+    for k, v := range pat {
+      related = append(related, having[pair{k, v}]...)
+    }
+    slices.Sort(related)
+
+    for _, j := range slices.Compact(related) {
+      if union, ok := combinePatterns(pat, all[j]); ok {
+        add(union)
+      }
+    }
+
+    for _, pm := range []*Patrun{old, new} {
+      for _, item := range pathBranches(pm.tree, pat) {
+        union := maps.Clone(pat)
+        union[item.key] = item.val
+        add(union)
+      }
+    }
+  }
+
+  return subjects
+}
+
+//return the properties of the branches at each node on the pattern's path,
+//leaving out the pattern's own keys, in key order
+func pathBranches(n node, pat map[string]string) []pair {
+  var found []pair
+
+  if n.key == "" {
+    return nil
+  }
+
+  for _, item := range appendMapPairs(nil, pat) {
+    found = appendBranches(found, n, pat)
+
+    next, ok := n.value[item.key].value[item.val]
+    if !ok {
+      return found
+    }
+    n = next
+  }
+
+  return appendBranches(found, n, pat)
+}
+
+func appendBranches(found []pair, n node, pat map[string]string) []pair {
+  for _, key := range slices.Sorted(maps.Keys(n.value)) {
+    if _, ok := pat[key]; ok {
+      continue
+    }
+    for _, val := range slices.Sorted(maps.Keys(n.value[key].value)) {
+      found = append(found, pair{key, val})
+    }
+  }
+
+  return found
+}
+
+//return the union of two patterns, unless they give a property different values
+func combinePatterns(a, b map[string]string) (map[string]string, bool) {
+  union := make(map[string]string, len(a) + len(b))
+
+  for k, v := range a {
+    union[k] = v
+  }
+
+  for k, v := range b {
+    if existing, ok := union[k]; ok && existing != v {
+      return nil, false
+    }
+    union[k] = v
+  }
+
+  return union, true
+}
+
+//return a string that uniquely identifies the properties of a pattern
+func patternKey(pat map[string]string) string {
+  var id []byte
+
+  for _, item := range appendMapPairs(nil, pat) {
+    id = append(id, item.key...)
+    id = append(id, 0)
+    id = append(id, item.val...)
+    id = append(id, 0)
+  }
+
+  return string(id)
+}
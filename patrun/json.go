@@ -0,0 +1,177 @@
+package patrun
+
+import (
+  "encoding/json"
+  "fmt"
+  "maps"
+  "slices"
+)
+
+//DataCodecs convert the data stored against each pattern to and from JSON
+type DataCodec interface {
+  Encode(data interface{}) (json.RawMessage, error)
+  Decode(raw json.RawMessage) (interface{}, error)
+}
+
+//Encodes data with encoding/json and decodes it into the generic types,
+//ie strings, float64, bool, []interface{} and map[string]interface{}. Used when no codec is given.
+type JSONCodec struct{}
+
+//Same as JSONCodec but decodes into a value of type T
+type TypedCodec[T any] struct{}
+
+//Keeps the data as a json.RawMessage so it can be decoded later
+type RawCodec struct{}
+
+//Encodes data as the name it was registered under, eg for functions that can't
+//be encoded as JSON. Data is looked up the same way Diff compares data, so
+//functions made by the same function literal match each other and encoding
+//them fails rather than picking one of their names.
+type RegistryCodec map[string]interface{}
+
+//the exported form of each pattern
+type jsonPattern struct {
+  Match map[string]string
+  Data json.RawMessage
+}
+
+func (c JSONCodec) Encode(data interface{}) (json.RawMessage, error) {
+  return json.Marshal(data)
+}
+
+func (c JSONCodec) Decode(raw json.RawMessage) (interface{}, error) {
+  var data interface{}
+
+  err := json.Unmarshal(raw, &data)
+
+  return data, err
+}
+
+func (c TypedCodec[T]) Encode(data interface{}) (json.RawMessage, error) {
+  return json.Marshal(data)
+}
+
+func (c TypedCodec[T]) Decode(raw json.RawMessage) (interface{}, error) {
+  var data T
+
+  err := json.Unmarshal(raw, &data)
+
+  return data, err
+}
+
+func (c RawCodec) Encode(data interface{}) (json.RawMessage, error) {
+  if raw, ok := data.(json.RawMessage); ok {
+    return raw, nil
+  }
+
+  return json.Marshal(data)
+}
+
+func (c RawCodec) Decode(raw json.RawMessage) (interface{}, error) {
+  return append(json.RawMessage{}, raw...), nil
+}
+
+func (c RegistryCodec) Encode(data interface{}) (json.RawMessage, error) {
+  var names []string
+
+  for _, name := range slices.Sorted(maps.Keys(c)) {
+    if dataEqual(c[name], data) {
+      names = append(names, name)
+    }
+  }
+
+  switch len(names) {
+  case 0:
+    return nil, fmt.Errorf("patrun: data %v is not registered", formatData(data))
+  case 1:
+    return json.Marshal(names[0])
+  }
+
+  //closures made by the same function literal can't be told apart
+  return nil, fmt.Errorf("patrun: data %v matches more than one registered name %q", formatData(data), names)
+}
+
+func (c RegistryCodec) Decode(raw json.RawMessage) (interface{}, error) {
+  var name string
+
+  if err := json.Unmarshal(raw, &name); err != nil {
+    return nil, err
+  }
+
+  data, ok := c[name]
+  if !ok {
+    return nil, fmt.Errorf("patrun: %q is not registered", name)
+  }
+
+  return data, nil
+}
+
+//Same as ToJSON but the data is encoded with the codec, nil uses JSONCodec
+func (p *Patrun) ToJSONWith(codec DataCodec) ([]byte, error) {
+  if codec == nil {
+    codec = JSONCodec{}
+  }
+
+  //an empty matcher gives null, as ToJSON always has
+  var items []jsonPattern
+
+  for item := range p.All() {
+    raw, err := codec.Encode(item.Data)
+    if err != nil {
+      return nil, fmt.Errorf("patrun: pattern %v: %w", formatMatch(item.Match), err)
+    }
+
+    items = append(items, jsonPattern{item.Match, raw})
+  }
+
+  return json.Marshal(items)
+}
+
+//Create a matcher from the output of ToJSON. The data is decoded with the codec, nil uses JSONCodec.
+func FromJSON(b []byte, codec DataCodec) (*Patrun, error) {
+  p := &Patrun{}
+
+  if err := p.LoadJSON(b, codec); err != nil {
+    return nil, err
+  }
+
+  return p, nil
+}
+
+//Add the patterns from the output of ToJSON to this matcher. Patterns are
+//added with Add so the Customiser is applied. Nothing is added if any pattern
+//fails to decode or is rejected by the Schema.
+func (p *Patrun) LoadJSON(b []byte, codec DataCodec) error {
+  if codec == nil {
+    codec = JSONCodec{}
+  }
+
+  var items []jsonPattern
+
+  if err := json.Unmarshal(b, &items); err != nil {
+    return fmt.Errorf("patrun: %w", err)
+  }
+
+  var data = make([]interface{}, len(items))
+
+  for k := range items {
+    var err error
+
+    data[k], err = codec.Decode(items[k].Data)
+    if err != nil {
+      return fmt.Errorf("patrun: pattern %d: %w", k, err)
+    }
+    if data[k] == nil {
+      return fmt.Errorf("patrun: pattern %d: no data", k)
+    }
+    if err := p.Validate(items[k].Match); err != nil {
+      return err
+    }
+  }
+
+  for k := range items {
+    p.Add(items[k].Match, data[k])
+  }
+
+  return nil
+}
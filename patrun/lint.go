@@ -0,0 +1,340 @@
+package patrun
+
+import (
+  "fmt"
+  "maps"
+  "slices"
+  "strings"
+  "unicode"
+)
+
+//How serious a lint finding is
+type LintSeverity int
+
+const (
+  //Worth knowing about, usually an intended consequence of the matching rules
+  LintInfo LintSeverity = iota
+  //Probably a mistake in the rule set
+  LintWarning
+  //A pattern that can never be returned
+  LintError
+)
+
+func (s LintSeverity) String() string {
+  switch s {
+  case LintInfo:
+    return "info"
+  case LintWarning:
+    return "warning"
+  case LintError:
+    return "error"
+  }
+  return fmt.Sprintf("LintSeverity(%d)", int(s))
+}
+
+//The checks made by Lint
+const (
+  //Find never returns the pattern, even when given the pattern itself
+  LintUnreachable = "unreachable"
+  //A subject that matches the pattern gets the data of a pattern that doesn't contain it
+  LintShadowed = "shadowed"
+  //The pattern's data is the same as the data of the pattern it overrides
+  LintRedundant = "redundant"
+  //The pattern differs from another only by the case or whitespace of its keys and values
+  LintNearDuplicate = "near-duplicate"
+)
+
+//Returned by Lint to describe a problem with a pattern
+type LintFinding struct {
+  Severity LintSeverity
+  //One of LintUnreachable, LintShadowed, LintRedundant or LintNearDuplicate
+  Check string
+  //The pattern the finding is about
+  Match map[string]string
+  //The other pattern involved, if any
+  Related map[string]string
+  //A subject that demonstrates the problem, if any
+  Subject map[string]string
+  Message string
+}
+
+func (f LintFinding) String() string {
+  return fmt.Sprintf("%v: %v: %v: %v", f.Severity, f.Check, formatMatch(f.Match), f.Message)
+}
+
+//Analyse the registered patterns for problems. Findings are ordered by
+//severity, most serious first, then in List order. Patterns that Find can
+//never return are errors. Patterns that lose some of their subjects to a
+//pattern earlier in key order are reported as information, since that is
+//how the matching rules work but can be surprising. Patterns whose data
+//repeats the data of the nearest shorter pattern on their path, and patterns
+//that differ from another only by case or whitespace, are warnings. Lint
+//looks at the tree alone, registered middleware and modifiers aren't called.
+func (p *Patrun) Lint() []LintFinding {
+  var findings []LintFinding
+  var all []Pattern
+  var unreachable = map[string]bool{}
+
+  for item := range p.All() {
+    all = append(all, item)
+  }
+
+  for _, item := range all {
+    match := p.treeMatch(item.Match)
+    if match == nil || !equalMatch(match, item.Match) {
+      unreachable[patternKey(item.Match)] = true
+
+      msg := "Find never returns this pattern"
+      if match != nil {
+        msg = fmt.Sprintf("Find with the pattern as the subject returns %v instead", describeMatch(match))
+      }
+      for k, v := range item.Match {
+        if v == "" {
+          msg = fmt.Sprintf("the empty value for %v can never be matched", k)
+        }
+      }
+
+      findings = append(findings, LintFinding{Severity: LintError, Check: LintUnreachable, Match: item.Match, Related: match, Subject: item.Match, Message: msg})
+    }
+  }
+
+  findings = append(findings, p.lintShadowed(unreachable)...)
+
+  for _, item := range all {
+    if parent, found := p.parentPattern(item.Match); found && dataEqual(item.Data, parent.Data) {
+      findings = append(findings, LintFinding{Severity: LintWarning, Check: LintRedundant, Match: item.Match, Related: parent.Match,
+        Message: fmt.Sprintf("data <%v> is the same as the data of %v", formatData(item.Data), describeMatch(parent.Match))})
+    }
+  }
+
+  var normal = map[string]map[string]string{}
+  for _, item := range all {
+    id := normalKey(item.Match)
+    if first, ok := normal[id]; ok {
+      findings = append(findings, LintFinding{Severity: LintWarning, Check: LintNearDuplicate, Match: item.Match, Related: first,
+        Message: fmt.Sprintf("differs from %v only by case or whitespace", describeMatch(first))})
+    } else {
+      normal[id] = item.Match
+    }
+  }
+
+  slices.SortStableFunc(findings, func(a, b LintFinding) int {
+    if a.Severity != b.Severity {
+      return int(b.Severity) - int(a.Severity)
+    }
+    return comparePatterns(a.Match, b.Match)
+  })
+
+  return findings
+}
+
+//return the pattern the tree gives the data of for the subject, nil if there
+//is none. Lint describes the tree, so the middleware, modifiers and Schema
+//that Explain goes through are left out.
+func (p *Patrun) treeMatch(pat map[string]string) map[string]string {
+  var match map[string]string
+
+  s := findPool.Get().(*findScratch)
+
+  s.pairs = appendMapPairs(s.pairs[:0], pat)
+  if data, _ := p.walk(s, false, true); data != nil {
+    match = matchMap(s.match)
+  }
+
+  findPool.Put(s)
+
+  return match
+}
+
+//report each pattern that loses subjects to a branch earlier in key order,
+//once for each earlier key. Below a node the walk follows the first of the
+//subject's keys that has a branch, so a pattern under a later key loses the
+//subjects that also have an earlier sibling key, unless following the rest of
+//the pattern below that sibling finds no data or leads back to the pattern.
+//This is worked out in one pass over the tree by comparing each branch with
+//the earlier keys at the same level. Only subjects that add one sibling
+//property are tried, subjects with more properties can lose in more ways.
+func (p *Patrun) lintShadowed(unreachable map[string]bool) []LintFinding {
+  var findings []LintFinding
+
+  if p.tree.key == "" {
+    return nil
+  }
+
+  var visit func(n node, path []pair, inherited []pair)
+  visit = func(n node, path []pair, inherited []pair) {
+    var keys = slices.Sorted(maps.Keys(n.value))
+
+    for i, key := range keys {
+      for _, val := range slices.Sorted(maps.Keys(n.value[key].value)) {
+        child := n.value[key].value[val]
+        here := append(slices.Clip(path), pair{key, val})
+
+        for _, suffix := range patternsBelow(child, []pair{{key, val}}) {
+          match := pairsMap(path, suffix)
+          if unreachable[patternKey(match)] {
+            continue
+          }
+
+          for _, earlier := range keys[:i] {
+            if subject, winner, ok := shadowedBy(n.value[earlier], earlier, path, inherited, suffix); ok {
+              findings = append(findings, LintFinding{Severity: LintInfo, Check: LintShadowed, Match: match, Related: winner, Subject: subject,
+                Message: fmt.Sprintf("subjects like %v get the data of %v instead", describeMatch(subject), describeMatch(winner))})
+            }
+          }
+        }
+
+        var below = inherited
+        if child.data != nil {
+          below = here
+        }
+        visit(child, here, below)
+      }
+    }
+  }
+
+  //the root pattern is an empty list, nil means no data above
+  var root []pair
+  if p.tree.data != nil {
+    root = []pair{}
+  }
+  visit(p.tree, nil, root)
+
+  return findings
+}
+
+//follow the rest of a pattern below each value of an earlier sibling key, the
+//same way the walk does, and return the first subject that ends up with the
+//data of a pattern that doesn't contain it. inherited is the last pattern with
+//data above the branch, nil if there is none, and the walk backtracks instead
+//of giving up when it hasn't found any data.
+func shadowedBy(branch node, key string, path []pair, inherited []pair, suffix []pair) (map[string]string, map[string]string, bool) {
+  for _, val := range slices.Sorted(maps.Keys(branch.value)) {
+    var current = branch.value[val]
+
+    //the walk can't follow a node with an empty key
+    if current.key == "" {
+      continue
+    }
+
+    var here = append(slices.Clip(path), pair{key, val})
+    var winner = inherited
+    var lost = false
+
+    if current.data != nil {
+      winner = here
+    }
+
+    for _, item := range suffix {
+      next, ok := current.value[item.key].value[item.val]
+      if !ok {
+        if winner == nil {
+          break
+        }
+        lost = true
+        continue
+      }
+
+      current = next
+      here = append(here, item)
+      if current.data != nil {
+        winner = slices.Clone(here)
+      }
+    }
+
+    //the walk only keeps a winner that skipped part of the pattern
+    if winner != nil && (lost || len(winner) < len(here)) {
+      return pairsMap(path, append([]pair{{key, val}}, suffix...)), pairsMap(winner), true
+    }
+  }
+
+  return nil, nil, false
+}
+
+//return the properties of every pattern in the branch, starting with the
+//properties given for the branch itself
+func patternsBelow(n node, path []pair) [][]pair {
+  var found [][]pair
+
+  if n.data != nil {
+    found = append(found, path)
+  }
+
+  for _, key := range slices.Sorted(maps.Keys(n.value)) {
+    for _, val := range slices.Sorted(maps.Keys(n.value[key].value)) {
+      found = append(found, patternsBelow(n.value[key].value[val], append(slices.Clip(path), pair{key, val}))...)
+    }
+  }
+
+  return found
+}
+
+func pairsMap(lists ...[]pair) map[string]string {
+  var result = map[string]string{}
+
+  for _, list := range lists {
+    for _, item := range list {
+      result[item.key] = item.val
+    }
+  }
+
+  return result
+}
+
+//find the nearest pattern with data on the path to this one, which is the pattern it overrides
+func (p *Patrun) parentPattern(pat map[string]string) (Pattern, bool) {
+  var keys = sortKeys(pat)
+
+  for n := len(keys) - 1; n >= 0; n-- {
+    var prefix = map[string]string{}
+    for _, key := range keys[:n] {
+      prefix[key] = pat[key]
+    }
+
+    if item, found := p.lookup(prefix); found {
+      return item, true
+    }
+  }
+
+  return Pattern{}, false
+}
+
+func equalMatch(a, b map[string]string) bool {
+  return len(a) == len(b) && containsMatch(a, b)
+}
+
+//return true if every key and value in sub is also in pat
+func containsMatch(pat, sub map[string]string) bool {
+  for k, v := range sub {
+    if val, ok := pat[k]; !ok || val != v {
+      return false
+    }
+  }
+  return true
+}
+
+func describeMatch(pat map[string]string) string {
+  if len(pat) == 0 {
+    return "the root pattern"
+  }
+  return formatMatch(pat)
+}
+
+//a key for the pattern that ignores case and whitespace
+func normalKey(pat map[string]string) string {
+  normal := func(s string) string {
+    return strings.ToLower(strings.Map(func(r rune) rune {
+      if unicode.IsSpace(r) {
+        return -1
+      }
+      return r
+    }, s))
+  }
+
+  var items = map[string]string{}
+  for k, v := range pat {
+    items[normal(k)] = normal(v)
+  }
+
+  return patternKey(items)
+}
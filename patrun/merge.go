@@ -0,0 +1,115 @@
+package patrun
+
+import (
+  "fmt"
+)
+
+//Decides the outcome when both matchers in a Merge have the same pattern.
+//Return the pattern to keep, or an error to abandon the merge.
+type MergePolicy func(pat map[string]string, ours Pattern, theirs Pattern) (Pattern, error)
+
+//Returned by Merge to describe a pattern that was registered in both matchers
+type MergeConflict struct {
+  Match map[string]string
+  Ours interface{}
+  Theirs interface{}
+  Result interface{}
+}
+
+//Returned by Merge to describe what was changed
+type MergeReport struct {
+  //Number of patterns that were only in the other matcher
+  Added int
+  //Every pattern registered in both matchers, in List order
+  Conflicts []MergeConflict
+}
+
+var (
+  //Keep the data already registered in this matcher
+  KeepOurs MergePolicy = func(pat map[string]string, ours Pattern, theirs Pattern) (Pattern, error) {
+    return ours, nil
+  }
+
+  //Replace the data with the data from the other matcher
+  TakeTheirs MergePolicy = func(pat map[string]string, ours Pattern, theirs Pattern) (Pattern, error) {
+    return theirs, nil
+  }
+
+  //Abandon the merge with an error wrapping ErrConflict
+  FailOnConflict MergePolicy = func(pat map[string]string, ours Pattern, theirs Pattern) (Pattern, error) {
+    return ours, fmt.Errorf("%w: %v", ErrConflict, formatMatch(pat))
+  }
+)
+
+//Return a policy that stores the result of the custom function, keeping the modifier from this matcher
+func CombineWith(combine func(pat map[string]string, ours interface{}, theirs interface{}) interface{}) MergePolicy {
+  return func(pat map[string]string, ours Pattern, theirs Pattern) (Pattern, error) {
+    ours.Data = combine(pat, ours.Data, theirs.Data)
+
+    return ours, nil
+  }
+}
+
+//Add every pattern from the other matcher to this one. The data and modifiers
+//are copied as is, the Customiser isn't called, and modifiers that implement
+//ModifierCloner are copied so the matchers don't share their state. When both matchers have the
+//same pattern the policy decides what is kept, a nil policy is the same as
+//FailOnConflict. If the policy returns an error, or a pattern is rejected by
+//this matcher's Schema, this matcher is left unchanged.
+func (p *Patrun) Merge(other *Patrun, policy MergePolicy) (MergeReport, error) {
+  var report MergeReport
+  var changes []Pattern
+
+  if policy == nil {
+    policy = FailOnConflict
+  }
+
+  for item := range other.All() {
+    if err := p.Validate(item.Match); err != nil {
+      return report, err
+    }
+
+    ours, found := p.lookup(item.Match)
+
+    if !found {
+      report.Added++
+      changes = append(changes, item)
+      continue
+    }
+
+    result, err := policy(item.Match, ours, item)
+    if err != nil {
+      return report, err
+    }
+
+    report.Conflicts = append(report.Conflicts, MergeConflict{item.Match, ours.Data, item.Data, result.Data})
+    changes = append(changes, result)
+  }
+
+  for k := range changes {
+    //the matchers don't share the state of modifiers, the same as Clone
+    p.insert(changes[k].Match, changes[k].Data, cloneModifier(changes[k].Modifier))
+  }
+
+  return report, nil
+}
+
+//return the pattern registered with exactly these properties
+func (p *Patrun) lookup(pat map[string]string) (Pattern, bool) {
+  var currentNode = p.tree
+  var keys = sortKeys(pat)
+
+  for k := range keys {
+    currentNode = currentNode.value[keys[k]].value[pat[keys[k]]]
+
+    if currentNode.key == "" {
+      return Pattern{}, false
+    }
+  }
+
+  if currentNode.data == nil {
+    return Pattern{}, false
+  }
+
+  return Pattern{pat, currentNode.data, currentNode.modifier}, true
+}
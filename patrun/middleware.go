@@ -0,0 +1,45 @@
+package patrun
+
+import (
+  "context"
+)
+
+//A step in the middleware chain around Find. It is given the matcher being
+//searched and returns the data for the subject, nil if nothing matches, or an
+//error.
+type FindFunc func(ctx context.Context, pm *Patrun, subject map[string]string, exact bool) (interface{}, error)
+
+//Middleware wraps every Find, FindExact, FindMany and the string, error and
+//context variants. It can change the subject before calling next, change the
+//result after, or return without calling next at all, for example to serve a
+//cached result or deny access.
+type Middleware func(next FindFunc) FindFunc
+
+//Register middleware around Find. Middleware is applied in registration
+//order, the first registered is the outermost and sees each call first. The
+//end of the chain validates the subject against the Schema, walks the tree
+//and applies the pattern's modifier. Register middleware before the matcher
+//is shared between goroutines.
+func (p *Patrun) Use(middleware ...Middleware) *Patrun {
+  p.middleware = append(p.middleware, middleware...)
+
+  //the chain isn't bound to p, the matcher is passed in on each call so
+  //copies and clones search their own tree
+  var find FindFunc = findNext
+  for k := len(p.middleware) - 1; k >= 0; k-- {
+    find = p.middleware[k](find)
+  }
+  p.chain = find
+
+  return p
+}
+
+//run the subject through the middleware chain, or straight to its end when
+//there is no middleware
+func (p *Patrun) find(ctx context.Context, pat map[string]string, exact bool) (interface{}, error) {
+  if p.chain != nil {
+    return p.chain(ctx, p, pat, exact)
+  }
+
+  return findNext(ctx, p, pat, exact)
+}
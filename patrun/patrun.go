@@ -22,12 +22,15 @@
 package patrun
 
 import (
+  "context"
   "sort"
   "fmt"
   "strings"
-  "encoding/json"
   "regexp"
   "reflect"
+  "iter"
+  "slices"
+  "sync"
 )
 
 type node struct {
@@ -41,7 +44,7 @@ type node struct {
 type Pattern struct {
     Match map[string]string
     Data interface{}
-    Modifier Modifiers
+    Modifier Modifiers `json:"-"`
 }
 
 //Modifiers allow you to customise the results for the Find and Remove methods
@@ -50,27 +53,64 @@ type Modifiers interface {
   Remove(pm *Patrun, pat map[string]string, data interface{}) bool
 }
 
+//Modifiers that keep several values for a pattern, or store something other
+//than the data they were added with, can also implement ModifierData. When
+//Add stores the modifier, or Remove keeps the pattern, the pattern's data is
+//replaced with Data so List, ToJSON and snapshots see the same value as Find.
+type ModifierData interface {
+  Data() interface{}
+}
+
+//the data to store against a pattern added with the modifier
+func modifiedData(custom Modifiers, data interface{}) interface{} {
+  if m, ok := custom.(ModifierData); ok && m.Data() != nil {
+    return m.Data()
+  }
+
+  return data
+}
+
 //Customisers allow custom logic to be added when processing patterns
 type Customiser interface {
   Add(pm *Patrun, pat map[string]string, data interface{}) Modifiers
 }
 
 //Patrun is the main object, specify Custom when creating to allow custom logic to be applied when manipulating patterns
+//and Schema to restrict the patterns and subjects that are accepted.
+//
+//Copying a Patrun value shares the decision tree with the original, use Clone to get an independent copy.
 type Patrun struct {
   tree node
   Custom Customiser
+  Schema *Schema
+
+  middleware []Middleware
+  chain FindFunc
 }
 
 
 //Register a pattern, and the object that will be returned if an input matches.
+//Patterns rejected by the Schema are not added, use Validate to find out why.
 func (p *Patrun) Add(pat map[string]string, data interface{}) *Patrun {
 
+    if p.Validate(pat) != nil {
+      return p
+    }
+
     var custom Modifiers
 
     if p.Custom != nil {
       custom = p.Custom.Add(p, pat, data)
     }
 
+    p.insert(pat, modifiedData(custom, data), custom)
+
+    return p
+}
+
+//store the data and modifier against the pattern, creating any missing nodes
+func (p *Patrun) insert(pat map[string]string, data interface{}, custom Modifiers) {
+
     if p.tree.key == "" {
       p.tree = node{"root", map[string]node{}, nil, nil}
     }
@@ -88,9 +128,10 @@ func (p *Patrun) Add(pat map[string]string, data interface{}) *Patrun {
       val = pat[key]
 
       lastNode = currentNode
-      currentNode = currentNode.value[key]
 
-      if currentNode.key == "" {
+      //keys can be empty, so look for the key node itself rather than its key
+      var found bool
+      if currentNode, found = currentNode.value[key]; !found {
         lastNode.value[key] = node{key, map[string]node{}, nil, nil}
         currentNode = lastNode.value[key]
       }
@@ -99,10 +140,11 @@ func (p *Patrun) Add(pat map[string]string, data interface{}) *Patrun {
       currentNode = currentNode.value[val]
       if currentNode.key == "" {
         justCreated = true
+        //only the pattern's own node holds the modifier, nodes on the way to it have no data to modify
         if k == len(keys) - 1 {
           lastNode.value[val] = node{val, map[string]node{}, data, custom}
         } else {
-          lastNode.value[val] = node{val, map[string]node{}, nil, custom}
+          lastNode.value[val] = node{val, map[string]node{}, nil, nil}
         }
         currentNode = lastNode.value[val]
       } else {
@@ -121,8 +163,6 @@ func (p *Patrun) Add(pat map[string]string, data interface{}) *Patrun {
       p.tree.data = data
       p.tree.modifier = custom
     }
-
-    return p
 }
 
 //Same as Add but using simple string notation instead of a map type. eg "a:1,b:2" is the equivalent to map[string]string{"a":"1","b":"2"}
@@ -135,16 +175,16 @@ func (p *Patrun) AddString(pat string, data interface{}) *Patrun {
 //Return the unique match for this subject, or nil if not found. The
 //properties of the subject are matched against the patterns previously
 //added, and the most specifc pattern wins. Unknown properties in the
-//subject are ignored.
+//subject are ignored. Subjects rejected by the Schema never match. When
+//middleware is registered with Use the call goes through it, and an error
+//from the chain gives nil.
 func (p *Patrun) Find(pat map[string]string) interface{} {
   return p.findItem(pat, false)
 }
 
 //Same as Find but using simple string notation
 func (p *Patrun) FindString(pat string) interface{} {
-  mapData := createMap(pat)
-
-  return p.Find(mapData)
+  return p.findStringItem(pat, false)
 }
 
 //Same as Find but only matches where all properties match will be returned.
@@ -154,40 +194,123 @@ func (p *Patrun)FindExact(pat map[string]string) interface{} {
 
 //Same as FindExact but using simple string notation
 func (p *Patrun) FindExactString(pat string) interface{} {
-  mapData := createMap(pat)
+  return p.findStringItem(pat, true)
+}
 
-  return p.FindExact(mapData)
+//Return the pattern added with exactly these properties, its raw data and
+//its modifier, without going through modifiers, middleware or the Schema.
+//Unlike FindExact this never gives the data of a less specific pattern.
+func (p *Patrun) Lookup(pat map[string]string) (Pattern, bool) {
+  return p.lookup(pat)
 }
 
 func (p *Patrun) findItem(pat map[string]string, exact bool) interface{} {
-  var keys = sortKeys(pat)
+  if p.chain != nil {
+    data, _ := p.chain(context.Background(), p, pat, exact)
+    return data
+  }
+
+  s := findPool.Get().(*findScratch)
+
+  s.pairs = appendMapPairs(s.pairs[:0], pat)
+  if !p.accepts(s.pairs) {
+    findPool.Put(s)
+    return nil
+  }
+
+  lastData, lastModifier := p.walk(s, exact, false)
+
+  findPool.Put(s)
+
+  if lastModifier != nil {
+    lastData = lastModifier.Find(p, pat, lastData)
+  }
+
+  return lastData
+}
+
+func (p *Patrun) findStringItem(pat string, exact bool) interface{} {
+  s := findPool.Get().(*findScratch)
+
+  s.pairs = appendStringPairs(s.pairs[:0], pat)
+
+  //the chain needs a map, middleware may keep it so it is always a new one
+  if p.chain != nil {
+    subject := matchMap(s.pairs)
+    findPool.Put(s)
+
+    data, _ := p.chain(context.Background(), p, subject, exact)
+
+    return data
+  }
+
+  if !p.accepts(s.pairs) {
+    findPool.Put(s)
+    return nil
+  }
 
+  lastData, lastModifier := p.walk(s, exact, false)
+
+  findPool.Put(s)
+
+  //modifiers expect a map so only build one when there is a modifier to call
+  if lastModifier != nil {
+    lastData = lastModifier.Find(p, createMap(pat), lastData)
+  }
+
+  return lastData
+}
+
+//check a subject's sorted properties against the schema, if there is one
+func (p *Patrun) accepts(pairs []pair) bool {
+  return p.Schema == nil || p.Schema.check(pairs) == nil
+}
+
+//walk the tree using the sorted subject properties held in the scratch space
+//and return the data and modifier of the most specific match. When tracing,
+//the properties of the pattern that supplied the data are left in s.match.
+func (p *Patrun) walk(s *findScratch, exact bool, trace bool) (interface{}, Modifiers) {
   var currentNode = p.tree
   var lastGoodNode = currentNode
-  var foundKeys []string
+  var foundKeys = 0
   var lastData interface{} = p.tree.data
   var lastModifier Modifiers = p.tree.modifier
-  var stars []node
+  var stars = s.stars[:0]
   var keyPointer = 0
 
-  for keyPointer < len(keys) {
-    var key = keys[keyPointer]
-    var val = pat[key]
+  if trace {
+    s.path = s.path[:0]
+    s.depths = s.depths[:0]
+    s.match = s.match[:0]
+  }
 
+  for keyPointer < len(s.pairs) {
+    var key = s.pairs[keyPointer].key
+    var val = s.pairs[keyPointer].val
 
     currentNode = currentNode.value[key].value[val]
 
     if currentNode.key != "" {
       if len(lastGoodNode.value) > 0 {
         stars = append(stars, lastGoodNode)
+        if trace {
+          s.depths = append(s.depths, len(s.path))
+        }
       }
 
       lastGoodNode = currentNode
-      foundKeys = append(foundKeys, key)
+      foundKeys++
+      if trace {
+        s.path = append(s.path, s.pairs[keyPointer])
+      }
+      //the modifier goes with the data, a node passed through on the way to a longer pattern doesn't change it
       if lastGoodNode.data != nil {
         lastData = lastGoodNode.data
+        lastModifier = lastGoodNode.modifier
+        if trace {
+          s.match = append(s.match[:0], s.path...)
+        }
       }
-      lastModifier = lastGoodNode.modifier
 
       keyPointer++
 
@@ -196,6 +319,10 @@ func (p *Patrun) findItem(pat map[string]string, exact bool) interface{} {
 
         stars = stars[:len(stars)-1]
         lastGoodNode = currentNode
+        if trace {
+          s.path = s.path[:s.depths[len(s.depths) - 1]]
+          s.depths = s.depths[:len(s.depths) - 1]
+        }
 
     } else {
 
@@ -205,22 +332,26 @@ func (p *Patrun) findItem(pat map[string]string, exact bool) interface{} {
 
   }
 
-  if exact && len(foundKeys) != len(keys) {
-    lastData = nil
-  }
+  //don't let pooled scratch space keep old trees alive
+  clear(stars[:cap(stars)])
+  s.stars = stars[:0]
 
-  if lastModifier != nil {
-    lastData = lastModifier.Find(p, pat, lastData)
+  if exact && foundKeys != len(s.pairs) {
+    lastData = nil
   }
 
-
-  return lastData
-
-
+  return lastData, lastModifier
 }
 
 //Remove this pattern, and it's object, from the matcher.
 func (p *Patrun) Remove(pat map[string]string) {
+  p.remove(pat, func(modifier Modifiers, data interface{}) (bool, error) {
+    return modifier.Remove(p, pat, data), nil
+  })
+}
+
+//remove the pattern, asking okToDel first when the pattern has a modifier
+func (p *Patrun) remove(pat map[string]string, okToDel func(modifier Modifiers, data interface{}) (bool, error)) error {
   var keys = sortKeys(pat)
 
   var currentNode = p.tree
@@ -233,9 +364,8 @@ func (p *Patrun) Remove(pat map[string]string) {
     key = keys[k]
     val = pat[key]
 
-    currentNode = currentNode.value[key]
-
-    if currentNode.key != "" {
+    var found bool
+    if currentNode, found = currentNode.value[key]; found {
       lastParent = currentNode
       lastGoodNode = currentNode
     }
@@ -258,22 +388,36 @@ func (p *Patrun) Remove(pat map[string]string) {
       item = lastParent.value[val]
     }
 
-    var okToDel = true
-
     if lastGoodNode.modifier != nil {
-      okToDel = lastGoodNode.modifier.Remove(p, pat, item.data)
-    }
-    if okToDel {
-      item.data = nil
-      item.modifier = nil
-      if len(pat) == 0 {
-        p.tree = node{p.tree.key, p.tree.value, nil, nil}
-      } else {
-        lastParent.value[val] = item
+      ok, err := okToDel(lastGoodNode.modifier, item.data)
+      if err != nil {
+        return err
+      }
+
+      if !ok {
+        //the modifier kept the pattern, store the value it now gives
+        if m, isData := lastGoodNode.modifier.(ModifierData); isData && m.Data() != nil {
+          item.data = m.Data()
+          if len(pat) == 0 {
+            p.tree.data = item.data
+          } else {
+            lastParent.value[val] = item
+          }
+        }
+        return nil
       }
     }
+
+    item.data = nil
+    item.modifier = nil
+    if len(pat) == 0 {
+      p.tree = node{p.tree.key, p.tree.value, nil, nil}
+    } else {
+      lastParent.value[val] = item
+    }
   }
 
+  return nil
 }
 
 //Same as Remove but using simple string notation
@@ -291,21 +435,37 @@ func (p *Patrun) RemoveString(pat string)  {
 //pattern-partial exactly are returned.
 func (p *Patrun)List(pat map[string]string, exact bool) []Pattern {
   var items []Pattern
-  var keyMap []string
 
-  if pat == nil {
-    pat = map[string]string{}
+  for item := range p.Query(pat, exact) {
+    items = append(items, item)
   }
 
-  if p.tree.data != nil {
-    items = append(items, createMatchList(keyMap, p.tree.data, p.tree.modifier))
+  return items
+}
+
+//Return an iterator over all the registered patterns, in the same order as List.
+func (p *Patrun) All() iter.Seq[Pattern] {
+  return p.Query(nil, false)
+}
+
+//Same as List but returns an iterator. The tree is walked as the patterns are
+//consumed, and walking stops as soon as the caller breaks out of the loop.
+func (p *Patrun) Query(pat map[string]string, exact bool) iter.Seq[Pattern] {
+  if pat == nil {
+    pat = map[string]string{}
   }
 
-  if p.tree.key != "" {
+  return func(yield func(Pattern) bool) {
+    if p.tree.data != nil {
+      if !yield(createMatchList(nil, p.tree.data, p.tree.modifier)) {
+        return
+      }
+    }
 
-    descendTree(&items, pat, exact, true, p.tree.value, keyMap)
+    if p.tree.key != "" {
+      descendTree(yield, pat, exact, p.tree.value, nil)
+    }
   }
-  return items
 }
 
 //Same as List but using simepl, string notation
@@ -316,11 +476,10 @@ func (p *Patrun) ListString(pat string, exact bool) []Pattern {
   return p.List(mapData, exact)
 }
 
-//Generate JSON representation of the tree.
-func (p *Patrun)ToJSON() []byte {
-  b, _ := json.Marshal(p.List(nil, false))
-
-  return b
+//Generate JSON representation of the tree. This is the List of all patterns
+//and can be loaded back with FromJSON.
+func (p *Patrun)ToJSON() ([]byte, error) {
+  return p.ToJSONWith(nil)
 }
 
 //Generate a string representation of the decision tree for debugging.
@@ -369,11 +528,9 @@ func formatMatch(items map[string]string) string {
 }
 
 
-func descendTree(items *[]Pattern, pat map[string]string, exact bool, rootLevel bool, values map[string]node, keyMap []string) {
-
-  var localKeyMap []string
-
-  copy(localKeyMap, keyMap)
+//walk the tree in key order, yielding each pattern that matches. Returns false
+//once yield asks to stop.
+func descendTree(yield func(Pattern) bool, pat map[string]string, exact bool, values map[string]node, keyMap []string) bool {
 
   var keys []string
   for k := range values {
@@ -385,24 +542,28 @@ func descendTree(items *[]Pattern, pat map[string]string, exact bool, rootLevel
     var key = keys[k]
     var val = values[key]
 
-    if rootLevel {
-      keyMap = []string{}
-    }
-
     if val.data == nil && len(val.value) > 0 {
-      descendTree(items, pat, exact, false, val.value, append(keyMap, key))
+      if !descendTree(yield, pat, exact, val.value, append(keyMap, key)) {
+        return false
+      }
 
     } else if val.data != nil {
-      localKeyMap = append(keyMap, val.key)
+      localKeyMap := append(keyMap, val.key)
       if validatePatternMatch(pat, exact, localKeyMap) {
-        *items = append(*items, createMatchList(localKeyMap, val.data, val.modifier))
+        if !yield(createMatchList(localKeyMap, val.data, val.modifier)) {
+          return false
+        }
       }
 
       if len(val.value) > 0 {
-        descendTree(items, pat, exact, false, val.value, append(keyMap, val.key))
+        if !descendTree(yield, pat, exact, val.value, append(keyMap, val.key)) {
+          return false
+        }
       }
     }
   }
+
+  return true
 }
 
 func validatePatternMatch(pat map[string]string, exact bool, matchedKeys []string) bool {
@@ -473,18 +634,10 @@ func formatData(data interface{}) string {
 func createMap(pat string) map[string]string {
     mapData := map[string]string{}
 
-    items := strings.Split(pat, ",")
-
-    for k := range items {
-      item := strings.TrimSpace(items[k])
-
-      if len(item) > 0 {
-        parts := strings.Split(item, ":")
-        if len(parts) == 2 {
-          mapData[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
-        }
-      }
+    var pairs = appendStringPairs(nil, pat)
 
+    for k := range pairs {
+      mapData[pairs[k].key] = pairs[k].val
     }
 
     return mapData
@@ -512,7 +665,11 @@ func gexval(pattern string, value string) bool {
 
   pattern = fmt.Sprintf("^%v$", pattern)
 
-  r = regexp.MustCompile(pattern)
+  //the pattern is escaped so this shouldn't fail, but never panic on user input
+  r, err := regexp.Compile(pattern)
+  if err != nil {
+    return false
+  }
 
   return r.MatchString(value)
 
@@ -526,6 +683,78 @@ func escregexp(restr string) string {
 
 }
 
+//scratch space reused across Find calls so that lookups don't allocate
+type findScratch struct {
+  pairs []pair
+  stars []node
+
+  //only used when tracing
+  path []pair
+  depths []int
+  match []pair
+}
+
+var findPool = sync.Pool{New: func() interface{} { return new(findScratch) }}
+
+type pair struct {
+  key string
+  val string
+}
+
+func comparePairs(a, b pair) int {
+  return strings.Compare(a.key, b.key)
+}
+
+//append the properties of the map sorted by key
+func appendMapPairs(pairs []pair, pat map[string]string) []pair {
+  for k, v := range pat {
+    pairs = append(pairs, pair{k, v})
+  }
+  slices.SortFunc(pairs, comparePairs)
+
+  return pairs
+}
+
+//append the properties in simple string notation sorted by key, without
+//building a map. Malformed items are skipped and later duplicate keys win,
+//the same as createMap.
+func appendStringPairs(pairs []pair, pat string) []pair {
+  var start = len(pairs)
+  var item string
+
+  for len(pat) > 0 {
+    if i := strings.IndexByte(pat, ','); i >= 0 {
+      item, pat = pat[:i], pat[i+1:]
+    } else {
+      item, pat = pat, ""
+    }
+
+    item = strings.TrimSpace(item)
+    if len(item) == 0 {
+      continue
+    }
+
+    i := strings.IndexByte(item, ':')
+    if i >= 0 && strings.IndexByte(item[i+1:], ':') < 0 {
+      pairs = append(pairs, pair{strings.TrimSpace(item[:i]), strings.TrimSpace(item[i+1:])})
+    }
+  }
+
+  slices.SortStableFunc(pairs[start:], comparePairs)
+
+  //drop duplicate keys, keeping the last one given
+  var out = start
+  for k := start; k < len(pairs); k++ {
+    if k + 1 < len(pairs) && pairs[k].key == pairs[k+1].key {
+      continue
+    }
+    pairs[out] = pairs[k]
+    out++
+  }
+
+  return pairs[:out]
+}
+
 func sortKeys(pat map[string]string) []string {
   var keys []string
   for k := range pat {
@@ -0,0 +1,425 @@
+// Package rules loads patrun patterns from declarative rule files
+//
+// Rule files are written in a small subset of YAML, so they can be parsed
+// without any dependencies outside the standard library:
+//
+//  # sales tax rules
+//  version: 1
+//  rules:
+//    - match: {}
+//      data: "0.0"
+//    - match:
+//        country: IE
+//      data: "0.23"
+//      meta:
+//        owner: tax-team
+//    - match: {country: IE, type: food}
+//      data: "0.048"
+//      priority: 10
+//
+//The schema is:
+//
+//  version   optional, must be 1
+//  rules     required, a list of rules, each with:
+//    match     required, a map of property names to values, {} matches everything
+//    data      required, the value returned when the rule matches
+//    priority  optional integer, default 0
+//    meta      optional, a map of names to values that is kept with the rule but not used for matching
+//
+//Values are always strings, use quotes when a value contains any of :#,{}[] or
+//starts with a special character. Comments start with # and indentation must use spaces.
+//Block maps, block lists, flow maps ({a: 1}) and the empty list ([]) are supported.
+//
+//When more than one rule has the same match, the rule with the highest
+//priority is used. Priority does not change which of two different patterns
+//is found, that is still decided by patrun's specificity rules.
+package rules
+
+import (
+  "bytes"
+  "fmt"
+  "io"
+  "os"
+  "sort"
+  "strconv"
+  "strings"
+
+  "github.com/colmharte/patrun-golang/patrun"
+)
+
+//A single rule from a rule file
+type Rule struct {
+  Match map[string]string
+  Data string
+  Priority int
+  Meta map[string]string
+  //Line number the rule starts on, 0 if it wasn't loaded from a file
+  Line int
+}
+
+//A parsed rule file
+type RuleSet struct {
+  //Name used when reporting errors, normally the file path
+  Name string
+  Rules []Rule
+}
+
+//A problem found in a rule file
+type Error struct {
+  File string
+  Line int
+  Msg string
+}
+
+func (e *Error) Error() string {
+  return fmt.Sprintf("%v:%v: %v", e.File, e.Line, e.Msg)
+}
+
+//All the problems found in a rule file
+type ErrorList []*Error
+
+func (l ErrorList) Error() string {
+  var msgs []string
+
+  for k := range l {
+    msgs = append(msgs, l[k].Error())
+  }
+
+  return strings.Join(msgs, "\n")
+}
+
+//Read and validate a rule file
+func Load(path string) (*RuleSet, error) {
+  src, err := os.ReadFile(path)
+  if err != nil {
+    return nil, err
+  }
+
+  return Parse(path, src)
+}
+
+//Same as Load but reads the rule file from r, name is used when reporting errors
+func Read(name string, r io.Reader) (*RuleSet, error) {
+  src, err := io.ReadAll(r)
+  if err != nil {
+    return nil, err
+  }
+
+  return Parse(name, src)
+}
+
+//Parse and validate the contents of a rule file. Syntax errors are returned as
+//an *Error, schema errors as an ErrorList holding every problem found.
+func Parse(name string, src []byte) (*RuleSet, error) {
+  doc, err := parseYAML(name, src)
+  if err != nil {
+    return nil, err
+  }
+
+  rs := &RuleSet{Name: name}
+  v := &validator{name: name}
+
+  if doc.kind != mapValue {
+    v.errorf(doc.line, "expected a map with a rules list")
+    return nil, v.errs
+  }
+
+  for _, key := range doc.keys {
+    field := doc.fields[key]
+
+    switch key {
+    case "version":
+      if field.kind != scalarValue || field.scalar != "1" {
+        v.errorf(field.line, "unsupported version, expected 1")
+      }
+    case "rules":
+      if field.kind != listValue {
+        v.errorf(field.line, "rules must be a list")
+        continue
+      }
+      for _, item := range field.items {
+        if rule, ok := v.rule(item); ok {
+          rs.Rules = append(rs.Rules, rule)
+        }
+      }
+    default:
+      v.errorf(field.line, "unknown field %q", key)
+    }
+  }
+
+  if _, ok := doc.fields["rules"]; !ok {
+    v.errorf(doc.line, "missing rules")
+  }
+
+  v.duplicates(rs.Rules)
+
+  if len(v.errs) > 0 {
+    return nil, v.errs
+  }
+
+  return rs, nil
+}
+
+type validator struct {
+  name string
+  errs ErrorList
+}
+
+func (v *validator) errorf(line int, format string, args ...interface{}) {
+  v.errs = append(v.errs, &Error{v.name, line, fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) rule(item *value) (Rule, bool) {
+  var rule = Rule{Line: item.line}
+  var count = len(v.errs)
+
+  if item.kind != mapValue {
+    v.errorf(item.line, "rule must be a map")
+    return rule, false
+  }
+
+  for _, key := range item.keys {
+    field := item.fields[key]
+
+    switch key {
+    case "match":
+      rule.Match = v.stringMap(field, "match")
+    case "data":
+      if field.kind != scalarValue {
+        v.errorf(field.line, "data must be a value")
+      }
+      rule.Data = field.scalar
+    case "priority":
+      priority, err := strconv.Atoi(field.scalar)
+      if field.kind != scalarValue || err != nil {
+        v.errorf(field.line, "priority must be an integer")
+      }
+      rule.Priority = priority
+    case "meta":
+      rule.Meta = v.stringMap(field, "meta")
+    default:
+      v.errorf(field.line, "unknown rule field %q", key)
+    }
+  }
+
+  if _, ok := item.fields["match"]; !ok {
+    v.errorf(item.line, "rule is missing match")
+  }
+  if _, ok := item.fields["data"]; !ok {
+    v.errorf(item.line, "rule is missing data")
+  }
+
+  return rule, len(v.errs) == count
+}
+
+func (v *validator) stringMap(field *value, name string) map[string]string {
+  var items = map[string]string{}
+
+  if field.kind != mapValue {
+    v.errorf(field.line, "%v must be a map", name)
+    return items
+  }
+
+  for _, key := range field.keys {
+    if field.fields[key].kind != scalarValue {
+      v.errorf(field.fields[key].line, "%v value for %q must be a value", name, key)
+      continue
+    }
+    items[key] = field.fields[key].scalar
+  }
+
+  return items
+}
+
+//rules with the same match and priority can't be told apart
+func (v *validator) duplicates(items []Rule) {
+  var seen = map[string]Rule{}
+
+  for _, rule := range items {
+    id := fmt.Sprintf("%q", patternPairs(rule.Match))
+
+    if first, ok := seen[id]; ok && first.Priority == rule.Priority {
+      v.errorf(rule.Line, "duplicate match %v with the same priority as line %v", formatMatch(rule.Match), first.Line)
+    } else if !ok || rule.Priority > first.Priority {
+      seen[id] = rule
+    }
+  }
+}
+
+//Return the rules that are used when building a matcher, ie the highest priority rule for each match, in file order
+func (rs *RuleSet) Effective() []Rule {
+  var best = map[string]int{}
+  var items []Rule
+
+  for k, rule := range rs.Rules {
+    id := fmt.Sprintf("%q", patternPairs(rule.Match))
+
+    if i, ok := best[id]; !ok || rule.Priority > rs.Rules[i].Priority {
+      best[id] = k
+    }
+  }
+
+  for k, rule := range rs.Rules {
+    if best[fmt.Sprintf("%q", patternPairs(rule.Match))] == k {
+      items = append(items, rule)
+    }
+  }
+
+  return items
+}
+
+//Create a matcher from the rules. The decode function converts each rule to
+//the data stored in the matcher, nil stores the rule's Data string.
+func (rs *RuleSet) Build(decode func(rule Rule) (interface{}, error)) (*patrun.Patrun, error) {
+  pm := &patrun.Patrun{}
+
+  if err := rs.AddTo(pm, decode); err != nil {
+    return nil, err
+  }
+
+  return pm, nil
+}
+
+//Same as Build but adds the rules to an existing matcher. Nothing is added if
+//any rule fails to decode or is rejected by the matcher's Schema, and the
+//error gives the rule's position.
+func (rs *RuleSet) AddTo(pm *patrun.Patrun, decode func(rule Rule) (interface{}, error)) error {
+  var items = rs.Effective()
+  var data = make([]interface{}, len(items))
+
+  for k, rule := range items {
+    if err := pm.Validate(rule.Match); err != nil {
+      return &Error{rs.Name, rule.Line, err.Error()}
+    }
+
+    if decode == nil {
+      data[k] = rule.Data
+      continue
+    }
+
+    var err error
+    data[k], err = decode(rule)
+    if err != nil {
+      return &Error{rs.Name, rule.Line, err.Error()}
+    }
+    if data[k] == nil {
+      return &Error{rs.Name, rule.Line, "no data"}
+    }
+  }
+
+  for k, rule := range items {
+    if err := pm.AddE(rule.Match, data[k]); err != nil {
+      return &Error{rs.Name, rule.Line, err.Error()}
+    }
+  }
+
+  return nil
+}
+
+//Create a rule set from the patterns in a matcher, in List order. The encode
+//function converts each data item to a string, nil allows string data only.
+func FromPatrun(pm *patrun.Patrun, encode func(data interface{}) (string, error)) (*RuleSet, error) {
+  rs := &RuleSet{}
+
+  for item := range pm.All() {
+    var data string
+    var err error
+
+    if encode != nil {
+      data, err = encode(item.Data)
+    } else if s, ok := item.Data.(string); ok {
+      data = s
+    } else {
+      err = fmt.Errorf("data %v is not a string", item.Data)
+    }
+
+    if err != nil {
+      return nil, fmt.Errorf("rules: pattern %v: %w", formatMatch(item.Match), err)
+    }
+
+    rs.Rules = append(rs.Rules, Rule{Match: item.Match, Data: data})
+  }
+
+  return rs, nil
+}
+
+//Write the rules in the rule file format, Parse reads the output back unchanged apart from line numbers
+func (rs *RuleSet) Encode(w io.Writer) error {
+  _, err := w.Write(rs.Marshal())
+
+  return err
+}
+
+//Same as Encode but returns the rule file contents
+func (rs *RuleSet) Marshal() []byte {
+  var b bytes.Buffer
+
+  b.WriteString("version: 1\n")
+
+  if len(rs.Rules) == 0 {
+    b.WriteString("rules: []\n")
+    return b.Bytes()
+  }
+
+  b.WriteString("rules:\n")
+
+  for _, rule := range rs.Rules {
+    if len(rule.Match) == 0 {
+      b.WriteString("  - match: {}\n")
+    } else {
+      b.WriteString("  - match:\n")
+      writeMap(&b, rule.Match, "      ")
+    }
+
+    fmt.Fprintf(&b, "    data: %v\n", quote(rule.Data))
+
+    if rule.Priority != 0 {
+      fmt.Fprintf(&b, "    priority: %v\n", rule.Priority)
+    }
+
+    if len(rule.Meta) > 0 {
+      b.WriteString("    meta:\n")
+      writeMap(&b, rule.Meta, "      ")
+    }
+  }
+
+  return b.Bytes()
+}
+
+func writeMap(b *bytes.Buffer, items map[string]string, indent string) {
+  var keys []string
+  for k := range items {
+    keys = append(keys, k)
+  }
+  sort.Strings(keys)
+
+  for _, key := range keys {
+    fmt.Fprintf(b, "%v%v: %v\n", indent, quote(key), quote(items[key]))
+  }
+}
+
+func patternPairs(pat map[string]string) []string {
+  var keys []string
+  for k := range pat {
+    keys = append(keys, k)
+  }
+  sort.Strings(keys)
+
+  var pairs []string
+  for _, key := range keys {
+    pairs = append(pairs, key, pat[key])
+  }
+
+  return pairs
+}
+
+func formatMatch(pat map[string]string) string {
+  var points []string
+  var pairs = patternPairs(pat)
+
+  for k := 0; k < len(pairs); k += 2 {
+    points = append(points, fmt.Sprintf("%v:%v", pairs[k], pairs[k + 1]))
+  }
+
+  return "{" + strings.Join(points, ", ") + "}"
+}
@@ -0,0 +1,382 @@
+package rules
+
+import (
+  "fmt"
+  "strconv"
+  "strings"
+)
+
+//the kinds of value found in a rule file
+const (
+  scalarValue = iota
+  mapValue
+  listValue
+)
+
+//a parsed value and the line it started on
+type value struct {
+  kind int
+  line int
+  scalar string
+  keys []string
+  fields map[string]*value
+  items []*value
+}
+
+//a line of the file with comments removed
+type line struct {
+  number int
+  indent int
+  text string
+}
+
+type parser struct {
+  name string
+  lines []line
+  pos int
+}
+
+//parse the YAML subset described in the package documentation
+func parseYAML(name string, src []byte) (*value, error) {
+  p := &parser{name: name}
+
+  for k, text := range strings.Split(strings.ReplaceAll(string(src), "\r\n", "\n"), "\n") {
+    if strings.HasPrefix(text, "\t") || strings.Contains(text[:len(text) - len(strings.TrimLeft(text, " \t"))], "\t") {
+      return nil, p.errorf(k + 1, "tabs can't be used for indentation")
+    }
+
+    text = strings.TrimRight(stripComment(text), " ")
+    trimmed := strings.TrimLeft(text, " ")
+
+    if trimmed == "" || trimmed == "---" {
+      continue
+    }
+
+    p.lines = append(p.lines, line{k + 1, len(text) - len(trimmed), trimmed})
+  }
+
+  if len(p.lines) == 0 {
+    return &value{kind: mapValue, line: 1, fields: map[string]*value{}}, nil
+  }
+
+  v, err := p.parseBlock(p.lines[0].indent)
+  if err != nil {
+    return nil, err
+  }
+
+  if p.pos < len(p.lines) {
+    return nil, p.errorf(p.lines[p.pos].number, "unexpected indentation")
+  }
+
+  return v, nil
+}
+
+func (p *parser) errorf(number int, format string, args ...interface{}) error {
+  return &Error{p.name, number, fmt.Sprintf(format, args...)}
+}
+
+func (p *parser) parseBlock(indent int) (*value, error) {
+  if strings.HasPrefix(p.lines[p.pos].text + " ", "- ") {
+    return p.parseList(indent)
+  }
+
+  return p.parseMap(indent)
+}
+
+func (p *parser) parseMap(indent int) (*value, error) {
+  v := &value{kind: mapValue, line: p.lines[p.pos].number, fields: map[string]*value{}}
+
+  for p.pos < len(p.lines) {
+    current := p.lines[p.pos]
+
+    if current.indent < indent {
+      break
+    }
+    if current.indent > indent {
+      return nil, p.errorf(current.number, "unexpected indentation")
+    }
+    if strings.HasPrefix(current.text + " ", "- ") {
+      return nil, p.errorf(current.number, "expected a key but found a list item")
+    }
+
+    key, rest, err := splitKey(current.text)
+    if err != nil {
+      return nil, p.errorf(current.number, "%v", err)
+    }
+    if _, ok := v.fields[key]; ok {
+      return nil, p.errorf(current.number, "duplicate key %q", key)
+    }
+
+    p.pos++
+
+    var child *value
+
+    if rest != "" {
+      child, err = parseInline(rest)
+      if err != nil {
+        return nil, p.errorf(current.number, "%v", err)
+      }
+      child.line = current.number
+
+    } else if p.pos < len(p.lines) && (p.lines[p.pos].indent > indent ||
+        (p.lines[p.pos].indent == indent && strings.HasPrefix(p.lines[p.pos].text + " ", "- "))) {
+      child, err = p.parseBlock(p.lines[p.pos].indent)
+      if err != nil {
+        return nil, err
+      }
+
+    } else {
+      child = &value{kind: scalarValue, line: current.number}
+    }
+
+    v.keys = append(v.keys, key)
+    v.fields[key] = child
+  }
+
+  return v, nil
+}
+
+func (p *parser) parseList(indent int) (*value, error) {
+  v := &value{kind: listValue, line: p.lines[p.pos].number}
+
+  for p.pos < len(p.lines) {
+    current := p.lines[p.pos]
+
+    if current.indent < indent || !strings.HasPrefix(current.text + " ", "- ") {
+      break
+    }
+    if current.indent > indent {
+      return nil, p.errorf(current.number, "unexpected indentation")
+    }
+
+    rest := strings.TrimLeft(current.text[1:], " ")
+
+    var child *value
+    var err error
+
+    if rest == "" {
+      p.pos++
+      if p.pos < len(p.lines) && p.lines[p.pos].indent > indent {
+        child, err = p.parseBlock(p.lines[p.pos].indent)
+      } else {
+        child = &value{kind: scalarValue, line: current.number}
+      }
+
+    } else if _, _, keyErr := splitKey(rest); keyErr == nil && rest[0] != '{' {
+      //a map starting on the same line as the dash, continue it at the indent of its first key
+      p.lines[p.pos] = line{current.number, current.indent + len(current.text) - len(rest), rest}
+      child, err = p.parseMap(p.lines[p.pos].indent)
+
+    } else {
+      p.pos++
+      child, err = parseInline(rest)
+      if child != nil {
+        child.line = current.number
+      }
+      if err != nil {
+        err = p.errorf(current.number, "%v", err)
+      }
+    }
+
+    if err != nil {
+      return nil, err
+    }
+
+    v.items = append(v.items, child)
+  }
+
+  return v, nil
+}
+
+//split "key: value" into its key and the rest of the line
+func splitKey(text string) (string, string, error) {
+  var key string
+  var rest string
+
+  if text[0] == '"' || text[0] == '\'' {
+    end := closingQuote(text)
+    if end < 0 {
+      return "", "", fmt.Errorf("unterminated quoted key")
+    }
+
+    var err error
+    key, err = unquote(text[:end + 1])
+    if err != nil {
+      return "", "", err
+    }
+
+    rest = text[end + 1:]
+    if !strings.HasPrefix(rest, ":") {
+      return "", "", fmt.Errorf("expected ':' after key")
+    }
+    rest = rest[1:]
+
+  } else {
+    i := strings.Index(text + " ", ": ")
+    if i < 0 {
+      return "", "", fmt.Errorf("expected 'key: value'")
+    }
+
+    key = strings.TrimSpace(text[:i])
+    rest = text[min(i + 1, len(text)):]
+  }
+
+  if rest != "" && rest[0] != ' ' {
+    return "", "", fmt.Errorf("expected a space after ':'")
+  }
+
+  return key, strings.TrimSpace(rest), nil
+}
+
+//parse a value written on the same line as its key, either a scalar or a flow map like {a: 1, b: 2}
+func parseInline(text string) (*value, error) {
+  if text == "[]" {
+    return &value{kind: listValue}, nil
+  }
+
+  if text[0] != '{' {
+    s, err := parseScalar(text)
+    return &value{kind: scalarValue, scalar: s}, err
+  }
+
+  if !strings.HasSuffix(text, "}") {
+    return nil, fmt.Errorf("unterminated '{'")
+  }
+
+  v := &value{kind: mapValue, fields: map[string]*value{}}
+
+  for _, item := range splitFlow(text[1:len(text) - 1]) {
+    item = strings.TrimSpace(item)
+    if item == "" {
+      continue
+    }
+
+    key, rest, err := splitKey(item)
+    if err != nil {
+      return nil, err
+    }
+    if _, ok := v.fields[key]; ok {
+      return nil, fmt.Errorf("duplicate key %q", key)
+    }
+
+    s, err := parseScalar(rest)
+    if err != nil {
+      return nil, err
+    }
+
+    v.keys = append(v.keys, key)
+    v.fields[key] = &value{kind: scalarValue, scalar: s}
+  }
+
+  return v, nil
+}
+
+//split the contents of a flow map on commas that aren't quoted
+func splitFlow(text string) []string {
+  var items []string
+  var quote byte
+  var start = 0
+
+  for k := 0; k < len(text); k++ {
+    switch {
+    case quote != 0:
+      if text[k] == '\\' && quote == '"' {
+        k++
+      } else if text[k] == quote {
+        quote = 0
+      }
+    case text[k] == '"' || text[k] == '\'':
+      quote = text[k]
+    case text[k] == ',':
+      items = append(items, text[start:k])
+      start = k + 1
+    }
+  }
+
+  return append(items, text[start:])
+}
+
+func parseScalar(text string) (string, error) {
+  if text == "" {
+    return "", nil
+  }
+
+  if text[0] == '"' || text[0] == '\'' {
+    end := closingQuote(text)
+    if end < 0 {
+      return "", fmt.Errorf("unterminated quoted value")
+    }
+    if end != len(text) - 1 {
+      return "", fmt.Errorf("unexpected text after quoted value")
+    }
+    return unquote(text)
+  }
+
+  if strings.ContainsAny(text[:1], "{}[]&*!|>%@`") {
+    return "", fmt.Errorf("unsupported value %q, use quotes", text)
+  }
+
+  return text, nil
+}
+
+//return the index of the quote that closes the one at the start of text
+func closingQuote(text string) int {
+  for k := 1; k < len(text); k++ {
+    if text[0] == '"' && text[k] == '\\' {
+      k++
+    } else if text[k] == text[0] {
+      if text[0] == '\'' && k + 1 < len(text) && text[k + 1] == '\'' {
+        k++
+        continue
+      }
+      return k
+    }
+  }
+
+  return -1
+}
+
+func unquote(text string) (string, error) {
+  if text[0] == '\'' {
+    return strings.ReplaceAll(text[1:len(text) - 1], "''", "'"), nil
+  }
+
+  s, err := strconv.Unquote(text)
+  if err != nil {
+    return "", fmt.Errorf("invalid quoted value %v", text)
+  }
+
+  return s, nil
+}
+
+//remove a # comment, unless it is inside quotes or part of a plain value
+func stripComment(text string) string {
+  var quote byte
+
+  for k := 0; k < len(text); k++ {
+    switch {
+    case quote != 0:
+      if text[k] == '\\' && quote == '"' {
+        k++
+      } else if text[k] == quote {
+        quote = 0
+      }
+    case (text[k] == '"' || text[k] == '\'') && (k == 0 || strings.ContainsRune(" :-{,", rune(text[k - 1]))):
+      quote = text[k]
+    case text[k] == '#' && (k == 0 || text[k - 1] == ' '):
+      return text[:k]
+    }
+  }
+
+  return text
+}
+
+//quote a key or value when it can't be written as a plain scalar
+func quote(s string) string {
+  if s == "" || s != strings.TrimSpace(s) || strings.ContainsAny(s, ":#,{}[]\"'\n\t\\") ||
+      strings.ContainsAny(s[:1], "-&*!|>%@`?") {
+    return strconv.Quote(s)
+  }
+
+  return s
+}
@@ -0,0 +1,146 @@
+package patrun
+
+import (
+  "fmt"
+  "regexp"
+  "slices"
+  "strconv"
+  "strings"
+)
+
+//Schema restricts the patterns that can be added to a matcher and the
+//subjects it will match. Set it on Patrun before adding patterns.
+type Schema struct {
+  //The allowed keys and the rule for each key's values, nil allows any key
+  Keys map[string]KeyRule
+  //Keys that every pattern and subject must have
+  Required []string
+  //The maximum number of properties in a pattern or subject, 0 for no limit
+  MaxProperties int
+}
+
+//KeyRule restricts the values allowed for a key. A value must satisfy both
+//Values and Pattern when they are set.
+type KeyRule struct {
+  //The allowed values, empty allows any value
+  Values []string
+  //A regular expression values must match, nil allows any value. Anchor it with ^ and $ to match the whole value.
+  Pattern *regexp.Regexp
+}
+
+//Returned by Validate to describe why a pattern or subject doesn't satisfy the schema
+type SchemaError struct {
+  //The pattern or subject that was rejected
+  Pattern map[string]string
+  //The key at fault, empty when the pattern has too many properties
+  Key string
+  Reason string
+}
+
+func (e *SchemaError) Error() string {
+  return fmt.Sprintf("patrun: invalid pattern %v: %v", formatMatch(e.Pattern), e.Reason)
+}
+
+//SchemaErrors match ErrInvalidPattern with errors.Is
+func (e *SchemaError) Unwrap() error {
+  return ErrInvalidPattern
+}
+
+//Check the pattern or subject against the schema, returning a *SchemaError if it isn't allowed
+func (s *Schema) Validate(pat map[string]string) error {
+  return s.check(appendMapPairs(nil, pat))
+}
+
+//Check the pattern or subject against the matcher's schema, always nil when there is no schema.
+func (p *Patrun) Validate(pat map[string]string) error {
+  if p.Schema == nil {
+    return nil
+  }
+  return p.Schema.Validate(pat)
+}
+
+//check sorted properties so Find can validate subjects without building a map
+func (s *Schema) check(pairs []pair) error {
+  fail := func(key string, reason string, args ...interface{}) error {
+    var pat = make(map[string]string, len(pairs))
+    for k := range pairs {
+      pat[pairs[k].key] = pairs[k].val
+    }
+    return &SchemaError{pat, key, fmt.Sprintf(reason, args...)}
+  }
+
+  if s.MaxProperties > 0 && len(pairs) > s.MaxProperties {
+    return fail("", "%v properties, at most %v are allowed", len(pairs), s.MaxProperties)
+  }
+
+  if s.Keys != nil {
+    for _, item := range pairs {
+      rule, ok := s.Keys[item.key]
+
+      if !ok {
+        if suggestion := s.closestKey(item.key); suggestion != "" {
+          return fail(item.key, "unknown key %v, did you mean %v", strconv.Quote(item.key), strconv.Quote(suggestion))
+        }
+        return fail(item.key, "unknown key %v", strconv.Quote(item.key))
+      }
+
+      if len(rule.Values) > 0 && !slices.Contains(rule.Values, item.val) {
+        return fail(item.key, "value %v is not allowed for %v, expected one of %v", strconv.Quote(item.val), item.key, rule.Values)
+      }
+
+      if rule.Pattern != nil && !rule.Pattern.MatchString(item.val) {
+        return fail(item.key, "value %v for %v does not match %v", strconv.Quote(item.val), item.key, rule.Pattern)
+      }
+    }
+  }
+
+  for _, key := range s.Required {
+    _, found := slices.BinarySearchFunc(pairs, key, func(item pair, key string) int {
+      return strings.Compare(item.key, key)
+    })
+
+    if !found {
+      return fail(key, "missing required key %v", strconv.Quote(key))
+    }
+  }
+
+  return nil
+}
+
+//suggest the allowed key closest to a misspelt one, if any is close enough
+func (s *Schema) closestKey(key string) string {
+  var best string
+  var bestDistance = len(key) / 3 + 1
+
+  for allowed := range s.Keys {
+    d := editDistance(key, allowed)
+    if d < bestDistance || (d == bestDistance && best != "" && allowed < best) {
+      best, bestDistance = allowed, d
+    }
+  }
+
+  return best
+}
+
+func editDistance(a, b string) int {
+  var row = make([]int, len(b) + 1)
+  for j := range row {
+    row[j] = j
+  }
+
+  for i := 1; i <= len(a); i++ {
+    var prev = row[0]
+    row[0] = i
+
+    for j := 1; j <= len(b); j++ {
+      var cost = 1
+      if a[i - 1] == b[j - 1] {
+        cost = 0
+      }
+
+      prev, row[j] = row[j], min(row[j] + 1, row[j - 1] + 1, prev + cost)
+    }
+  }
+
+  return row[len(b)]
+}
@@ -0,0 +1,310 @@
+package patrun
+
+import (
+  "bytes"
+  "encoding/binary"
+  "fmt"
+  "hash/crc32"
+  "io"
+  "sort"
+)
+
+//Snapshots start with a 16 byte header: the magic bytes, the format version,
+//reserved flags and the length of the body. The body is followed by the CRC-32
+//(IEEE) of the body. All integers in the header and trailer are big endian.
+//
+//The body holds the decision tree, each node is written as its key, a flag
+//byte, the encoded data when present and the number of children followed by
+//the children in key order. The flag is 1 when the node has data and 3 when
+//it also had a modifier, which isn't written. Only the root and value nodes
+//can have data, children have unique keys, value nodes can't be empty and the
+//root is only empty for an empty tree. Strings, data and counts are prefixed
+//with their length as an unsigned varint.
+const (
+  snapshotMagic = "PTRN"
+  snapshotVersion = 1
+  snapshotHeaderSize = 16
+)
+
+//Write a binary snapshot of the decision tree, with the data encoded by
+//JSONCodec. Use ReadFrom to load it. Modifiers are not written, only which
+//patterns had one, so they can be recreated by the Customiser when loaded.
+//Patterns with an empty value, which Find never returns, can't be written.
+func (p *Patrun) WriteTo(w io.Writer) (int64, error) {
+  return p.WriteSnapshot(w, nil)
+}
+
+//Same as WriteTo but the data is encoded with the codec, nil uses JSONCodec
+func (p *Patrun) WriteSnapshot(w io.Writer, codec DataCodec) (int64, error) {
+  if codec == nil {
+    codec = JSONCodec{}
+  }
+
+  var body bytes.Buffer
+
+  if err := writeSnapshotNode(&body, p.tree, codec, nil); err != nil {
+    return 0, err
+  }
+
+  var header = make([]byte, snapshotHeaderSize)
+  copy(header, snapshotMagic)
+  binary.BigEndian.PutUint16(header[4:], snapshotVersion)
+  binary.BigEndian.PutUint64(header[8:], uint64(body.Len()))
+
+  var trailer = binary.BigEndian.AppendUint32(nil, crc32.ChecksumIEEE(body.Bytes()))
+
+  var total int64
+  for _, part := range [][]byte{header, body.Bytes(), trailer} {
+    n, err := w.Write(part)
+    total += int64(n)
+    if err != nil {
+      return total, err
+    }
+  }
+
+  return total, nil
+}
+
+func writeSnapshotNode(b *bytes.Buffer, n node, codec DataCodec, path []string) error {
+  //the walk takes a value node with an empty key to be missing, so it can't be read back
+  if len(path) > 0 && len(path) % 2 == 0 && n.key == "" {
+    return fmt.Errorf("patrun: pattern %v has an empty value", formatMatch(convertListToMap(path)))
+  }
+
+  writeSnapshotBytes(b, []byte(n.key))
+
+  if n.data != nil {
+    raw, err := codec.Encode(n.data)
+    if err != nil {
+      return fmt.Errorf("patrun: pattern %v: %w", formatMatch(convertListToMap(path)), err)
+    }
+
+    if n.modifier != nil {
+      b.WriteByte(3)
+    } else {
+      b.WriteByte(1)
+    }
+    writeSnapshotBytes(b, raw)
+  } else {
+    b.WriteByte(0)
+  }
+
+  var keys []string
+  for k := range n.value {
+    keys = append(keys, k)
+  }
+  sort.Strings(keys)
+
+  b.Write(binary.AppendUvarint(nil, uint64(len(keys))))
+
+  for k := range keys {
+    //path alternates key and value nodes below the root, the same as List
+    if err := writeSnapshotNode(b, n.value[keys[k]], codec, append(path, keys[k])); err != nil {
+      return err
+    }
+  }
+
+  return nil
+}
+
+func writeSnapshotBytes(b *bytes.Buffer, data []byte) {
+  b.Write(binary.AppendUvarint(nil, uint64(len(data))))
+  b.Write(data)
+}
+
+//Replace the patterns in this matcher with those from a snapshot written by
+//WriteTo, decoding the data with JSONCodec. If a Customiser is set each
+//pattern is added with Add so that modifiers are recreated, otherwise the tree
+//is loaded directly and ErrSnapshotModifiers is returned if any pattern had a
+//modifier. The matcher is unchanged if the snapshot, or any pattern in it, is
+//rejected.
+func (p *Patrun) ReadFrom(r io.Reader) (int64, error) {
+  return p.ReadSnapshot(r, nil)
+}
+
+//Same as ReadFrom but the data is decoded with the codec, nil uses JSONCodec
+func (p *Patrun) ReadSnapshot(r io.Reader, codec DataCodec) (int64, error) {
+  if codec == nil {
+    codec = JSONCodec{}
+  }
+
+  var header = make([]byte, snapshotHeaderSize)
+
+  n, err := io.ReadFull(r, header)
+  var total = int64(n)
+  if err != nil {
+    return total, fmt.Errorf("%w: %v", ErrSnapshotFormat, err)
+  }
+
+  if string(header[:4]) != snapshotMagic {
+    return total, fmt.Errorf("%w: bad magic", ErrSnapshotFormat)
+  }
+  if version := binary.BigEndian.Uint16(header[4:]); version != snapshotVersion {
+    return total, fmt.Errorf("%w: %v", ErrSnapshotVersion, version)
+  }
+
+  var size = binary.BigEndian.Uint64(header[8:])
+
+  body, err := io.ReadAll(io.LimitReader(r, int64(min(size, 1 << 62))))
+  total += int64(len(body))
+  if err != nil {
+    return total, err
+  }
+  if uint64(len(body)) != size {
+    return total, fmt.Errorf("%w: truncated", ErrSnapshotFormat)
+  }
+
+  var trailer = make([]byte, 4)
+  n, err = io.ReadFull(r, trailer)
+  total += int64(n)
+  if err != nil {
+    return total, fmt.Errorf("%w: truncated", ErrSnapshotFormat)
+  }
+
+  if binary.BigEndian.Uint32(trailer) != crc32.ChecksumIEEE(body) {
+    return total, ErrSnapshotChecksum
+  }
+
+  d := &snapshotDecoder{body: body, codec: codec}
+
+  tree, err := d.node(nil, false)
+  if err != nil {
+    return total, err
+  }
+  if len(d.body) > 0 {
+    return total, fmt.Errorf("%w: unexpected data after tree", ErrSnapshotFormat)
+  }
+
+  loaded := Patrun{tree: tree}
+
+  if p.Schema != nil {
+    for item := range loaded.All() {
+      if err := p.Schema.Validate(item.Match); err != nil {
+        return total, err
+      }
+    }
+  }
+
+  if p.Custom == nil {
+    if d.modified != nil {
+      return total, fmt.Errorf("%w: pattern %v had a modifier", ErrSnapshotModifiers, formatMatch(d.modified))
+    }
+
+    p.tree = tree
+    return total, nil
+  }
+
+  p.tree = node{}
+
+  for item := range loaded.All() {
+    p.Add(item.Match, item.Data)
+  }
+
+  return total, nil
+}
+
+type snapshotDecoder struct {
+  body []byte
+  codec DataCodec
+
+  //the first pattern that had a modifier
+  modified map[string]string
+}
+
+func (d *snapshotDecoder) uvarint() (uint64, error) {
+  v, n := binary.Uvarint(d.body)
+  if n <= 0 {
+    return 0, fmt.Errorf("%w: bad length", ErrSnapshotFormat)
+  }
+  d.body = d.body[n:]
+
+  return v, nil
+}
+
+func (d *snapshotDecoder) bytes() ([]byte, error) {
+  size, err := d.uvarint()
+  if err != nil {
+    return nil, err
+  }
+  if size > uint64(len(d.body)) {
+    return nil, fmt.Errorf("%w: truncated", ErrSnapshotFormat)
+  }
+
+  data := d.body[:size]
+  d.body = d.body[size:]
+
+  return data, nil
+}
+
+func (d *snapshotDecoder) node(path []string, child bool) (node, error) {
+  var n node
+
+  key, err := d.bytes()
+  if err != nil {
+    return n, err
+  }
+  n.key = string(key)
+
+  //path alternates key and value nodes below the root, the same as writing
+  if child {
+    path = append(path, n.key)
+  }
+
+  if len(d.body) == 0 {
+    return n, fmt.Errorf("%w: truncated", ErrSnapshotFormat)
+  }
+  var flag = d.body[0]
+  d.body = d.body[1:]
+
+  //only the root and value nodes, an even depth, hold patterns, and an empty
+  //value node is taken to be missing
+  if (flag != 0 && len(path) % 2 == 1) || (n.key == "" && child && len(path) % 2 == 0) {
+    return n, fmt.Errorf("%w: bad node", ErrSnapshotFormat)
+  }
+
+  if flag == 3 && d.modified == nil {
+    d.modified = convertListToMap(path)
+  }
+
+  if flag == 1 || flag == 3 {
+    raw, err := d.bytes()
+    if err != nil {
+      return n, err
+    }
+
+    n.data, err = d.codec.Decode(raw)
+    if err != nil {
+      return n, err
+    }
+  } else if flag != 0 {
+    return n, fmt.Errorf("%w: bad flag", ErrSnapshotFormat)
+  }
+
+  count, err := d.uvarint()
+  if err != nil {
+    return n, err
+  }
+  //every child takes at least 3 bytes
+  if count > uint64(len(d.body)) / 3 {
+    return n, fmt.Errorf("%w: truncated", ErrSnapshotFormat)
+  }
+
+  if n.key == "" && !child && (flag != 0 || count > 0) {
+    return n, fmt.Errorf("%w: bad node", ErrSnapshotFormat)
+  }
+
+  n.value = make(map[string]node, count)
+
+  for i := uint64(0); i < count; i++ {
+    child, err := d.node(path, true)
+    if err != nil {
+      return n, err
+    }
+    if _, ok := n.value[child.key]; ok {
+      return n, fmt.Errorf("%w: duplicate key %q", ErrSnapshotFormat, child.key)
+    }
+    n.value[child.key] = child
+  }
+
+  return n, nil
+}
@@ -0,0 +1,91 @@
+package patrun
+
+import (
+  "unsafe"
+)
+
+//Returned by the Stats method to describe the size and shape of the decision tree
+type Stats struct {
+  //Number of registered patterns
+  Patterns int
+  //Number of key and value nodes in the tree, not counting the root
+  Nodes int
+  //Most properties in any one pattern
+  MaxDepth int
+  //Average number of properties per pattern
+  AvgDepth float64
+  //Widest branching for each property name, ie the most values below any one node for that key
+  FanOut map[string]int
+  //Number of distinct values registered for each property name
+  Values map[string]int
+  //Rough estimate of the memory used by the tree in bytes, excluding the data stored against patterns
+  MemoryBytes int64
+}
+
+//approximate overheads used when estimating memory, the exact figures depend on the Go runtime
+const (
+  mapHeaderBytes = 48
+  mapEntryBytes = int64(unsafe.Sizeof("")) + int64(unsafe.Sizeof(node{})) + 8
+)
+
+//Return statistics about the decision tree. The tree is walked once without
+//building the list of patterns.
+func (p *Patrun) Stats() Stats {
+  st := Stats{FanOut: map[string]int{}, Values: map[string]int{}}
+
+  var values = map[string]map[string]bool{}
+  var depths = 0
+
+  if p.tree.data != nil {
+    st.Patterns++
+  }
+
+  if p.tree.key != "" {
+    st.MemoryBytes = int64(unsafe.Sizeof(p.tree)) + mapHeaderBytes
+
+    statsTree(&st, values, &depths, p.tree, 0)
+  }
+
+  for key, vals := range values {
+    st.Values[key] = len(vals)
+  }
+
+  if st.Patterns > 0 {
+    st.AvgDepth = float64(depths) / float64(st.Patterns)
+  }
+
+  return st
+}
+
+func statsTree(st *Stats, values map[string]map[string]bool, depths *int, current node, depth int) {
+  for _, keyNode := range current.value {
+    st.Nodes++
+    st.MemoryBytes += mapEntryBytes + int64(len(keyNode.key)) + mapHeaderBytes
+
+    if len(keyNode.value) > st.FanOut[keyNode.key] {
+      st.FanOut[keyNode.key] = len(keyNode.value)
+    }
+
+    if values[keyNode.key] == nil {
+      values[keyNode.key] = map[string]bool{}
+    }
+
+    for _, valNode := range keyNode.value {
+      st.Nodes++
+      st.MemoryBytes += mapEntryBytes + int64(len(valNode.key)) + mapHeaderBytes
+
+      values[keyNode.key][valNode.key] = true
+
+      if valNode.data != nil {
+        st.Patterns++
+        *depths += depth + 1
+
+        if depth + 1 > st.MaxDepth {
+          st.MaxDepth = depth + 1
+        }
+      }
+
+      statsTree(st, values, depths, valNode, depth + 1)
+    }
+  }
+}
@@ -0,0 +1,340 @@
+package patrun
+
+import (
+  "github.com/colmharte/patrun-golang/patrun"
+  "github.com/colmharte/patrun-golang/patrun/custom"
+  "testing"
+  "fmt"
+  "strings"
+)
+
+func TestCustomMultiValue(t *testing.T) {
+  r := patrun.Patrun{Custom: &custom.MultiValue{}}
+
+  r.AddString("a:1", "A")
+  r.AddString("a:1", "B")
+  r.AddString("b:1", "C")
+  r.AddString("a:1,c:1", "D")
+
+  if fmt.Sprint(r.FindString("a:1")) != "[A B]" || fmt.Sprint(r.FindString("b:1")) != "[C]" {
+    t.Error("Find should return every value", r.FindString("a:1"), r.FindString("b:1"))
+  }
+  if fmt.Sprint(r.FindString("a:1,c:1")) != "[D]" || r.FindString("c:1") != nil {
+    t.Error("values are kept per pattern", r.FindString("a:1,c:1"))
+  }
+
+  r.RemoveString("a:1")
+  if fmt.Sprint(r.FindString("a:1")) != "[A]" {
+    t.Error("Remove should pop the last value", r.FindString("a:1"))
+  }
+
+  r.RemoveString("a:1")
+  if r.FindExactString("a:1") != nil || len(r.List(nil, false)) != 2 {
+    t.Error("Remove of the last value should remove the pattern", r.FindString("a:1"))
+  }
+
+  r.AddString("a:1", "E")
+  if fmt.Sprint(r.FindString("a:1")) != "[E]" {
+    t.Error("adding a removed pattern starts a new list", r.FindString("a:1"))
+  }
+}
+
+func TestCustomMultiValueTop(t *testing.T) {
+  r := patrun.Patrun{Custom: &custom.MultiValue{Top: true}}
+
+  r.AddString("a:1", "A")
+  r.AddString("a:1", "B")
+
+  if r.FindString("a:1") != "B" {
+    t.Error("Top should return the most recent value", r.FindString("a:1"))
+  }
+
+  r.RemoveString("a:1")
+  if r.FindString("a:1") != "A" {
+    t.Error("Remove should go back to the previous value", r.FindString("a:1"))
+  }
+}
+
+func TestCustomConstantProperties(t *testing.T) {
+  r := patrun.Patrun{Custom: custom.ConstantProperties{"foo": "true"}}
+
+  r.AddString("a:1", "foobar")
+
+  if r.FindExactString("a:1") != nil || r.FindExactString("a:1,foo:true") != "foobar" {
+    t.Error("the constant properties should be added to the pattern")
+  }
+}
+
+func TestCustomTransformResult(t *testing.T) {
+  r := patrun.Patrun{Custom: custom.TransformResult(func(data interface{}) interface{} {
+    return strings.ToUpper(data.(string))
+  })}
+
+  r.AddString("a:1", "bar")
+
+  if r.FindString("a:1") != "BAR" || r.FindString("a:2") != nil {
+    t.Error("Find should transform the result", r.FindString("a:1"))
+  }
+}
+
+func TestCustomReadOnly(t *testing.T) {
+  r := patrun.Patrun{Custom: custom.ReadOnly{}}
+
+  r.AddString("a:1", "A")
+  r.RemoveString("a:1")
+
+  if r.FindString("a:1") != "A" {
+    t.Error("Remove should be vetoed")
+  }
+
+  r.AddString("a:1", "B")
+  if r.FindString("a:1") != "B" {
+    t.Error("Add should still replace the data")
+  }
+}
+
+func TestCustomRefCounted(t *testing.T) {
+  r := patrun.Patrun{Custom: custom.RefCounted{}}
+
+  r.AddString("a:1", "A")
+  r.AddString("a:1", "A2")
+
+  r.RemoveString("a:1")
+  if r.FindString("a:1") != "A2" {
+    t.Error("the pattern should stay until every Add is removed", r.FindString("a:1"))
+  }
+
+  r.RemoveString("a:1")
+  if r.FindString("a:1") != nil {
+    t.Error("the pattern should be removed", r.FindString("a:1"))
+  }
+}
+
+func TestCustomChain(t *testing.T) {
+  r := patrun.Patrun{Custom: custom.Chain(
+    custom.ConstantProperties{"env": "prod"},
+    &custom.MultiValue{},
+    custom.TransformResult(func(data interface{}) interface{} {
+      return fmt.Sprintf("%v!", data)
+    }),
+    custom.RefCounted{},
+  )}
+
+  r.AddString("a:1", "A")
+  r.AddString("a:1", "B")
+
+  if r.FindString("a:1") != nil || r.FindString("a:1,env:prod") != "[A B]!" {
+    t.Error("the chain should apply every customiser in order", r.FindString("a:1,env:prod"))
+  }
+
+  r.RemoveString("a:1,env:prod")
+  if r.FindString("a:1,env:prod") != "[A]!" {
+    t.Error("Remove should reach every modifier", r.FindString("a:1,env:prod"))
+  }
+
+  r.RemoveString("a:1,env:prod")
+  if r.FindString("a:1,env:prod") != nil {
+    t.Error("the pattern should be removed when every modifier agrees", r.FindString("a:1,env:prod"))
+  }
+
+  r2 := patrun.Patrun{Custom: custom.Chain(custom.RefCounted{}, custom.ReadOnly{})}
+  r2.AddString("a:1", "A")
+  r2.RemoveString("a:1")
+
+  if r2.FindString("a:1") != "A" {
+    t.Error("any modifier should be able to veto Remove")
+  }
+}
+
+func TestCustomOverride(t *testing.T) {
+  type handler func(msg string) string
+
+  var audit []string
+
+  r := patrun.Patrun{Custom: custom.Override{}}
+
+  r.AddString("a:1", handler(func(msg string) string {
+    return "A(" + msg + ")"
+  }))
+
+  //layer auditing around the existing handler
+  r.AddString("a:1", custom.Overrider(func(prior interface{}) interface{} {
+    next := prior.(handler)
+    return handler(func(msg string) string {
+      audit = append(audit, msg)
+      return next(msg)
+    })
+  }))
+
+  //a more specific pattern can build on the general one
+  r.AddString("a:1,b:2", custom.Overrider(func(prior interface{}) interface{} {
+    next := prior.(handler)
+    return handler(func(msg string) string {
+      return "B(" + next(msg) + ")"
+    })
+  }))
+
+  if out := r.FindString("a:1").(handler)("x"); out != "A(x)" || fmt.Sprint(audit) != "[x]" {
+    t.Error("the override should call the prior handler", out, audit)
+  }
+  if out := r.FindString("a:1,b:2").(handler)("y"); out != "B(A(y))" || fmt.Sprint(audit) != "[x y]" {
+    t.Error("the prior of a new pattern is the less specific match", out, audit)
+  }
+
+  r.RemoveString("a:1")
+  if out := r.FindString("a:1").(handler)("z"); out != "A(z)" || len(audit) != 2 {
+    t.Error("Remove should pop back to the prior handler", out, audit)
+  }
+
+  r.RemoveString("a:1")
+  if r.FindExactString("a:1") != nil {
+    t.Error("Remove of the last handler should remove the pattern")
+  }
+
+  r.AddString("c:1", custom.Overrider(func(prior interface{}) interface{} {
+    if prior != nil {
+      t.Error("there is no prior data", prior)
+    }
+    return "C"
+  }))
+  r.AddString("c:1", "C2")
+
+  if r.FindString("c:1") != "C2" {
+    t.Error("plain data replaces the prior data", r.FindString("c:1"))
+  }
+  r.RemoveString("c:1")
+  if r.FindString("c:1") != "C" {
+    t.Error("Remove should pop back to the prior data", r.FindString("c:1"))
+  }
+}
+
+func TestCustomLongerPattern(t *testing.T) {
+  r := patrun.Patrun{Custom: &custom.MultiValue{}}
+
+  r.AddString("a:1", "X")
+  r.AddString("a:1,b:2,c:3", "Z")
+
+  if fmt.Sprint(r.FindString("a:1,b:2")) != "[X]" || fmt.Sprint(r.Find(map[string]string{"a":"1","b":"2"})) != "[X]" {
+    t.Error("a subject that stops part way to a longer pattern gets the shorter pattern's values", r.FindString("a:1,b:2"))
+  }
+  if fmt.Sprint(r.FindString("a:1,b:2,c:3")) != "[Z]" {
+    t.Error("the longer pattern keeps its own values", r.FindString("a:1,b:2,c:3"))
+  }
+
+  o := patrun.Patrun{Custom: custom.Override{}}
+
+  o.AddString("a:1", "X")
+  o.AddString("a:1,b:2,c:3", "Z")
+
+  if o.FindString("a:1,b:2") != "X" || o.FindString("a:1,b:2,c:3") != "Z" {
+    t.Error("a subject that stops part way to a longer pattern gets the shorter pattern's data", o.FindString("a:1,b:2"))
+  }
+
+  //removing the shorter pattern leaves nothing for the subject, rather than the longer pattern's data
+  r.RemoveString("a:1")
+  if r.FindString("a:1,b:2") != nil {
+    t.Error("nothing should match once the shorter pattern is removed", r.FindString("a:1,b:2"))
+  }
+}
+
+func TestCustomClone(t *testing.T) {
+  r := patrun.Patrun{Custom: custom.Chain(&custom.MultiValue{}, custom.RefCounted{})}
+
+  r.AddString("a:1", "A")
+  r.AddString("a:1", "B")
+
+  c := r.Clone()
+  c.RemoveString("a:1")
+
+  if fmt.Sprint(r.FindString("a:1")) != "[A B]" || fmt.Sprint(c.FindString("a:1")) != "[A]" {
+    t.Error("Remove on the Clone should not change the original", r.FindString("a:1"), c.FindString("a:1"))
+  }
+
+  c.RemoveString("a:1")
+  if c.FindString("a:1") != nil || fmt.Sprint(r.FindString("a:1")) != "[A B]" {
+    t.Error("the Clone keeps its own count", c.FindString("a:1"), r.FindString("a:1"))
+  }
+
+  o := patrun.Patrun{Custom: custom.Override{}}
+
+  o.AddString("a:1", "A")
+  o.AddString("a:1", "B")
+
+  oc := o.Clone()
+  oc.RemoveString("a:1")
+
+  if o.FindString("a:1") != "B" || oc.FindString("a:1") != "A" {
+    t.Error("Remove on the Clone should not pop the original's chain", o.FindString("a:1"), oc.FindString("a:1"))
+  }
+}
+
+func TestCustomOverrideStoresData(t *testing.T) {
+  o := patrun.Patrun{Custom: custom.Override{}}
+
+  o.AddString("a:1", "base")
+  o.AddString("a:1", custom.Overrider(func(prior interface{}) interface{} {
+    return "audit(" + prior.(string) + ")"
+  }))
+
+  if o.FindString("a:1") != "audit(base)" {
+    t.Error("Find should give the overridden data", o.FindString("a:1"))
+  }
+  if list := o.ListString("a:1", true); len(list) != 1 || list[0].Data != "audit(base)" {
+    t.Error("List should give the data the Overrider returned", list)
+  }
+  if out, err := o.ToJSON(); err != nil || string(out) != `[{"Match":{"a":"1"},"Data":"audit(base)"}]` {
+    t.Error("ToJSON should give the data the Overrider returned", string(out), err)
+  }
+
+  if err := o.AddE(map[string]string{"b": "1"}, custom.Overrider(func(prior interface{}) interface{} { return "B" })); err != nil || o.ListString("b:1", true)[0].Data != "B" {
+    t.Error("AddE should store the data the Overrider returned", err)
+  }
+}
+
+func TestCustomRemoveStoresData(t *testing.T) {
+  o := patrun.Patrun{Custom: custom.Override{}}
+
+  o.AddString("a:1", "A")
+  o.AddString("a:1", "B")
+  o.RemoveString("a:1")
+
+  if list := o.ListString("a:1", true); len(list) != 1 || list[0].Data != "A" {
+    t.Error("List should give the data Remove popped back to", list)
+  }
+  if out, err := o.ToJSON(); err != nil || strings.Contains(string(out), "B") || !strings.Contains(string(out), "A") {
+    t.Error("ToJSON should give the data Remove popped back to", string(out), err)
+  }
+
+  r := patrun.Patrun{Custom: custom.Chain(&custom.MultiValue{}, custom.RefCounted{})}
+
+  r.AddString("a:1", "A")
+  r.AddString("a:1", "B")
+  r.RemoveE(map[string]string{"a": "1"})
+
+  if list := r.ListString("a:1", true); len(list) != 1 || list[0].Data != "A" || fmt.Sprint(r.FindString("a:1")) != "[A]" {
+    t.Error("List should give the most recent value left", list, r.FindString("a:1"))
+  }
+}
+
+func TestCustomMerge(t *testing.T) {
+  base := &patrun.Patrun{Custom: &custom.MultiValue{}}
+
+  base.AddString("a:1", "A")
+  base.AddString("a:1", "B")
+
+  product := &patrun.Patrun{Custom: &custom.MultiValue{}}
+  if _, err := product.Merge(base, nil); err != nil {
+    t.Fatal("Merge should not fail", err)
+  }
+
+  product.RemoveString("a:1")
+  if fmt.Sprint(base.FindString("a:1")) != "[A B]" || fmt.Sprint(product.FindString("a:1")) != "[A]" {
+    t.Error("Remove after a Merge should not change the other matcher", base.FindString("a:1"), product.FindString("a:1"))
+  }
+
+  base.RemoveString("a:1")
+  base.RemoveString("a:1")
+  if base.FindString("a:1") != nil || fmt.Sprint(product.FindString("a:1")) != "[A]" {
+    t.Error("Remove on the other matcher should not change the merged one", base.FindString("a:1"), product.FindString("a:1"))
+  }
+}
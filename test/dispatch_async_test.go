@@ -0,0 +1,133 @@
+package patrun
+
+import (
+  "github.com/colmharte/patrun-golang/patrun/dispatch"
+  "testing"
+  "context"
+  "errors"
+  "sync/atomic"
+  "time"
+)
+
+func TestDispatchAsync(t *testing.T) {
+  d := &dispatch.Dispatcher{Pool: dispatch.PoolOptions{Workers: 4}}
+  d.AddString("a:1", func(ctx context.Context, msg map[string]interface{}) (interface{}, error) {
+    return msg["n"].(int) * 2, nil
+  })
+  d.AddString("a:2", func(ctx context.Context, msg map[string]interface{}) (interface{}, error) {
+    panic("boom")
+  })
+
+  var futures []*dispatch.Future
+  for n := 0; n < 20; n++ {
+    f, err := d.ActAsync(context.Background(), map[string]interface{}{"a": "1", "n": n})
+    if err != nil {
+      t.Fatal(err)
+    }
+    futures = append(futures, f)
+  }
+
+  for n, f := range futures {
+    if out, err := f.Wait(context.Background()); out != n * 2 || err != nil {
+      t.Error("ActAsync should deliver every reply", n, out, err)
+    }
+  }
+
+  f, _ := d.ActAsync(context.Background(), map[string]interface{}{"a": "2"})
+  <-f.Done()
+  var panicErr *dispatch.PanicError
+  if _, err := f.Wait(context.Background()); !errors.As(err, &panicErr) {
+    t.Error("panics should be recovered", err)
+  }
+
+  if _, err := d.ActAsync(context.Background(), map[string]interface{}{"a": "3"}); !errors.Is(err, dispatch.ErrNoHandler) {
+    t.Error("ActAsync should return NoHandlerError straight away", err)
+  }
+
+  if err := d.Shutdown(context.Background()); err != nil {
+    t.Error("Shutdown", err)
+  }
+  if _, err := d.ActAsync(context.Background(), map[string]interface{}{"a": "1", "n": 1}); err != dispatch.ErrClosed {
+    t.Error("ActAsync after Shutdown should fail", err)
+  }
+}
+
+//a dispatcher whose a:1 handler waits for release, with a single worker and a queue of one
+func blockedDispatcher(t *testing.T, backpressure dispatch.Backpressure) (*dispatch.Dispatcher, chan struct{}, *int32) {
+  var handled int32
+  release := make(chan struct{})
+  started := make(chan struct{}, 10)
+
+  d := &dispatch.Dispatcher{}
+  d.AddString("a:1", func(ctx context.Context, msg map[string]interface{}) (interface{}, error) {
+    started <- struct{}{}
+    <-release
+    atomic.AddInt32(&handled, 1)
+    return "A", nil
+  })
+  if err := d.SetPool(map[string]string{"a": "1"}, dispatch.PoolOptions{Workers: 1, QueueSize: 1, Backpressure: backpressure}); err != nil {
+    t.Fatal(err)
+  }
+
+  //one message running and one waiting fills the pool
+  d.ActAsync(context.Background(), map[string]interface{}{"a": "1"})
+  <-started
+  d.ActAsync(context.Background(), map[string]interface{}{"a": "1"})
+
+  return d, release, &handled
+}
+
+func TestDispatchBackpressure(t *testing.T) {
+  d, release, _ := blockedDispatcher(t, dispatch.BackpressureError)
+  if _, err := d.ActAsync(context.Background(), map[string]interface{}{"a": "1"}); err != dispatch.ErrQueueFull {
+    t.Error("a full queue should return ErrQueueFull", err)
+  }
+  if err := d.SetPool(map[string]string{"a": "1"}, dispatch.PoolOptions{}); err == nil {
+    t.Error("SetPool should fail once the pool has started")
+  }
+  close(release)
+  d.Shutdown(context.Background())
+
+  d, release, _ = blockedDispatcher(t, dispatch.BackpressureDrop)
+  f, err := d.ActAsync(context.Background(), map[string]interface{}{"a": "1"})
+  if _, ferr := f.Wait(context.Background()); err != nil || ferr != dispatch.ErrDropped {
+    t.Error("a full queue should drop the message", err, ferr)
+  }
+  close(release)
+  d.Shutdown(context.Background())
+
+  d, release, _ = blockedDispatcher(t, dispatch.BackpressureBlock)
+  ctx, cancel := context.WithTimeout(context.Background(), 10 * time.Millisecond)
+  defer cancel()
+  if _, err := d.ActAsync(ctx, map[string]interface{}{"a": "1"}); err != context.DeadlineExceeded {
+    t.Error("a full queue should block until the context ends", err)
+  }
+
+  done := make(chan *dispatch.Future)
+  go func() {
+    f, _ := d.ActAsync(context.Background(), map[string]interface{}{"a": "1"})
+    done <- f
+  }()
+  close(release)
+
+  if out, err := (<-done).Wait(context.Background()); out != "A" || err != nil {
+    t.Error("a blocked message should be queued once there is space", out, err)
+  }
+  d.Shutdown(context.Background())
+}
+
+func TestDispatchShutdownDrains(t *testing.T) {
+  d, release, handled := blockedDispatcher(t, dispatch.BackpressureBlock)
+
+  ctx, cancel := context.WithTimeout(context.Background(), 10 * time.Millisecond)
+  defer cancel()
+  if err := d.Shutdown(ctx); err != context.DeadlineExceeded {
+    t.Error("Shutdown should give up when the context ends", err)
+  }
+
+  close(release)
+
+  if err := d.Shutdown(context.Background()); err != nil || atomic.LoadInt32(handled) != 2 {
+    t.Error("Shutdown should wait for the running and queued messages", err, atomic.LoadInt32(handled))
+  }
+}
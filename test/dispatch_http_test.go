@@ -0,0 +1,125 @@
+package patrun
+
+import (
+  "github.com/colmharte/patrun-golang/patrun"
+  "github.com/colmharte/patrun-golang/patrun/dispatch"
+  "testing"
+  "context"
+  "errors"
+  "net/http"
+  "net/http/httptest"
+  "strings"
+  "time"
+)
+
+func mathServer(t *testing.T) (*httptest.Server, *dispatch.Client) {
+  d := &dispatch.Dispatcher{Timeout: 50 * time.Millisecond, Patrun: &patrun.Patrun{Schema: &patrun.Schema{MaxProperties: 4}}}
+
+  d.AddString("role:math,cmd:sum", func(ctx context.Context, msg map[string]interface{}) (interface{}, error) {
+    return msg["x"].(float64) + msg["y"].(float64), nil
+  })
+  d.AddString("role:math,cmd:zero", reply(0))
+  d.AddString("role:math,cmd:fail", func(ctx context.Context, msg map[string]interface{}) (interface{}, error) {
+    return nil, errors.New("failed")
+  })
+  d.AddString("role:math,cmd:panic", func(ctx context.Context, msg map[string]interface{}) (interface{}, error) {
+    panic("boom")
+  })
+  d.AddString("role:math,cmd:slow", func(ctx context.Context, msg map[string]interface{}) (interface{}, error) {
+    <-ctx.Done()
+    return nil, ctx.Err()
+  })
+
+  server := httptest.NewServer(&dispatch.Server{Dispatcher: d})
+  t.Cleanup(server.Close)
+
+  return server, &dispatch.Client{URL: server.URL}
+}
+
+func TestDispatchRemote(t *testing.T) {
+  _, client := mathServer(t)
+  ctx := context.Background()
+
+  if out, err := client.Act(ctx, map[string]interface{}{"role": "math", "cmd": "sum", "x": 1, "y": 2}); out != 3.0 || err != nil {
+    t.Error("Client.Act should return the reply", out, err)
+  }
+  if out, err := client.Act(ctx, map[string]interface{}{"role": "math", "cmd": "zero"}); out != 0.0 || err != nil {
+    t.Error("a zero reply should be sent", out, err)
+  }
+
+  var tests = []struct{
+    cmd string
+    kind string
+    status int
+    is error
+    msg string
+  }{
+    {"none", dispatch.KindNoHandler, http.StatusNotFound, dispatch.ErrNoHandler, "dispatch: no handler for cmd:none, role:math"},
+    {"fail", dispatch.KindError, http.StatusInternalServerError, nil, "failed"},
+    {"panic", dispatch.KindPanic, http.StatusInternalServerError, nil, "dispatch: handler panicked: boom"},
+    {"slow", dispatch.KindTimeout, http.StatusGatewayTimeout, context.DeadlineExceeded, "context deadline exceeded"},
+  }
+
+  for _, test := range tests {
+    _, err := client.Act(ctx, map[string]interface{}{"role": "math", "cmd": test.cmd})
+
+    var remote *dispatch.RemoteError
+    if !errors.As(err, &remote) || remote.Kind != test.kind || remote.StatusCode != test.status || err.Error() != test.msg {
+      t.Errorf("%v should fail with %v %v %q, got %#v", test.cmd, test.kind, test.status, test.msg, err)
+    }
+    if test.is != nil && !errors.Is(err, test.is) {
+      t.Errorf("%v should match %v", test.cmd, test.is)
+    }
+  }
+
+  _, err := client.Act(ctx, map[string]interface{}{"role": "math", "a": "1", "b": "2", "c": "3", "d": "4"})
+  if !errors.Is(err, patrun.ErrInvalidPattern) {
+    t.Error("schema errors should be sent as invalid", err)
+  }
+}
+
+func TestDispatchRemoteHandler(t *testing.T) {
+  _, client := mathServer(t)
+
+  //route math messages to the other process and handle the rest locally
+  d := &dispatch.Dispatcher{}
+  d.AddString("role:math", dispatch.Remote(client))
+  d.AddString("role:echo", func(ctx context.Context, msg map[string]interface{}) (interface{}, error) {
+    return msg["text"], nil
+  })
+
+  if out, err := d.Act(context.Background(), map[string]interface{}{"role": "math", "cmd": "sum", "x": 2.0, "y": 3.0}); out != 5.0 || err != nil {
+    t.Error("the remote handler should forward the message", out, err)
+  }
+  if out, err := d.Act(context.Background(), map[string]interface{}{"role": "echo", "text": "hi"}); out != "hi" || err != nil {
+    t.Error("local handlers still work", out, err)
+  }
+  if _, err := d.Act(context.Background(), map[string]interface{}{"role": "math", "cmd": "none"}); !errors.Is(err, dispatch.ErrNoHandler) {
+    t.Error("remote errors should be returned", err)
+  }
+}
+
+func TestDispatchServerRequests(t *testing.T) {
+  server, _ := mathServer(t)
+
+  resp, err := http.Get(server.URL)
+  if err != nil || resp.StatusCode != http.StatusMethodNotAllowed {
+    t.Error("GET should be refused", err)
+  }
+  resp.Body.Close()
+
+  for _, body := range []string{"{bad", "[1]", "null"} {
+    resp, err := http.Post(server.URL, "application/json", strings.NewReader(body))
+    if err != nil || resp.StatusCode != http.StatusBadRequest {
+      t.Error("the body must be a JSON object", body, err)
+    }
+    resp.Body.Close()
+  }
+
+  ctx, cancel := context.WithCancel(context.Background())
+  cancel()
+  client := &dispatch.Client{URL: server.URL}
+  if _, err := client.Act(ctx, map[string]interface{}{"role": "math"}); !errors.Is(err, context.Canceled) {
+    t.Error("Client.Act should use the context", err)
+  }
+}
@@ -0,0 +1,177 @@
+package patrun
+
+import (
+  "github.com/colmharte/patrun-golang/patrun"
+  "github.com/colmharte/patrun-golang/patrun/dispatch"
+  "testing"
+  "context"
+  "errors"
+  "fmt"
+  "reflect"
+  "sync"
+  "time"
+)
+
+func reply(value interface{}) dispatch.Handler {
+  return func(ctx context.Context, msg map[string]interface{}) (interface{}, error) {
+    return value, nil
+  }
+}
+
+func TestDispatchAct(t *testing.T) {
+  d := &dispatch.Dispatcher{}
+
+  d.AddString("role:math,cmd:sum", func(ctx context.Context, msg map[string]interface{}) (interface{}, error) {
+    return msg["x"].(float64) + msg["y"].(float64), nil
+  })
+  d.AddString("role:math", reply("math"))
+  d.Add(map[string]string{"role": "store", "id": "1", "ok": "true"}, reply("stored"))
+
+  sum, err := d.Act(context.Background(), map[string]interface{}{"role": "math", "cmd": "sum", "x": 1.0, "y": 2.0})
+  if sum != 3.0 || err != nil {
+    t.Error("Act should call the best matching handler", sum, err)
+  }
+
+  if out, _ := d.Act(context.Background(), map[string]interface{}{"role": "math", "cmd": "product"}); out != "math" {
+    t.Error("Act should fall back to the less specific handler", out)
+  }
+
+  if out, _ := d.Act(context.Background(), map[string]interface{}{"role": "store", "id": 1.0, "ok": true}); out != "stored" {
+    t.Error("numbers and booleans should match their string form", out)
+  }
+
+  _, err = d.Act(context.Background(), map[string]interface{}{"role": "none", "extra": []int{1}})
+
+  var noHandler *dispatch.NoHandlerError
+  if !errors.Is(err, dispatch.ErrNoHandler) || !errors.Is(err, patrun.ErrNotFound) || !errors.As(err, &noHandler) || noHandler.Message["role"] != "none" {
+    t.Error("Act should return a NoHandlerError", err)
+  }
+  if err.Error() != "dispatch: no handler for role:none" {
+    t.Error("NoHandlerError message", err)
+  }
+
+  if err := d.Remove(map[string]string{"role": "math"}); err != nil {
+    t.Error("Remove", err)
+  }
+  if _, err := d.Act(context.Background(), map[string]interface{}{"role": "math"}); !errors.Is(err, dispatch.ErrNoHandler) {
+    t.Error("a removed handler shouldn't be found", err)
+  }
+  if err := d.Remove(map[string]string{"role": "math"}); !errors.Is(err, patrun.ErrNotFound) {
+    t.Error("Remove of a missing handler", err)
+  }
+}
+
+func TestDispatchEmpty(t *testing.T) {
+  var d dispatch.Dispatcher
+
+  if _, err := d.Act(context.Background(), map[string]interface{}{"a": "1"}); !errors.Is(err, dispatch.ErrNoHandler) {
+    t.Error("an empty dispatcher has no handlers", err)
+  }
+  if err := d.AddString("a:1,b", reply("A")); !errors.Is(err, patrun.ErrInvalidPattern) {
+    t.Error("AddString should reject malformed patterns", err)
+  }
+  if err := d.AddString("a:1", nil); !errors.Is(err, patrun.ErrInvalidPattern) {
+    t.Error("Add should reject nil handlers", err)
+  }
+}
+
+func TestDispatchSchema(t *testing.T) {
+  d := &dispatch.Dispatcher{Patrun: &patrun.Patrun{Schema: &patrun.Schema{Required: []string{"role"}}}}
+
+  if err := d.AddString("cmd:sum", reply("A")); !errors.Is(err, patrun.ErrInvalidPattern) {
+    t.Error("Add should use the matcher's schema", err)
+  }
+
+  d.AddString("role:math", reply("math"))
+  if _, err := d.Act(context.Background(), map[string]interface{}{"cmd": "sum"}); !errors.Is(err, patrun.ErrInvalidPattern) {
+    t.Error("Act should return schema errors", err)
+  }
+}
+
+func TestDispatchPanic(t *testing.T) {
+  d := &dispatch.Dispatcher{}
+  d.AddString("a:1", func(ctx context.Context, msg map[string]interface{}) (interface{}, error) {
+    panic("boom")
+  })
+
+  for _, timeout := range []time.Duration{0, time.Second} {
+    d.Timeout = timeout
+
+    _, err := d.Act(context.Background(), map[string]interface{}{"a": "1"})
+
+    var panicErr *dispatch.PanicError
+    if !errors.As(err, &panicErr) || panicErr.Value != "boom" || len(panicErr.Stack) == 0 || err.Error() != "dispatch: handler panicked: boom" {
+      t.Error("Act should recover panics", timeout, err)
+    }
+  }
+}
+
+func TestDispatchTimeout(t *testing.T) {
+  release := make(chan struct{})
+  defer close(release)
+
+  d := &dispatch.Dispatcher{Timeout: 10 * time.Millisecond}
+  d.AddString("a:1", func(ctx context.Context, msg map[string]interface{}) (interface{}, error) {
+    <-release
+    return "late", nil
+  })
+  d.AddString("a:2", func(ctx context.Context, msg map[string]interface{}) (interface{}, error) {
+    <-ctx.Done()
+    return nil, ctx.Err()
+  })
+
+  if _, err := d.Act(context.Background(), map[string]interface{}{"a": "1"}); err != context.DeadlineExceeded {
+    t.Error("Act should stop waiting at the timeout", err)
+  }
+  if _, err := d.Act(context.Background(), map[string]interface{}{"a": "2"}); err != context.DeadlineExceeded {
+    t.Error("handlers should see the timeout", err)
+  }
+
+  ctx, cancel := context.WithCancel(context.Background())
+  cancel()
+  if _, err := d.Act(ctx, map[string]interface{}{"a": "1"}); err != context.Canceled {
+    t.Error("Act should return when the context is cancelled", err)
+  }
+}
+
+func TestDispatchMiddleware(t *testing.T) {
+  var log []string
+
+  trace := func(name string) dispatch.Middleware {
+    return func(next dispatch.Handler) dispatch.Handler {
+      return func(ctx context.Context, msg map[string]interface{}) (interface{}, error) {
+        log = append(log, name)
+        out, err := next(ctx, msg)
+        return fmt.Sprintf("%v(%v)", name, out), err
+      }
+    }
+  }
+
+  d := &dispatch.Dispatcher{}
+  d.AddString("a:1", reply("A"))
+  d.Use(trace("outer")).Use(trace("inner"))
+
+  out, err := d.Act(context.Background(), map[string]interface{}{"a": "1"})
+  if out != "outer(inner(A))" || err != nil || !reflect.DeepEqual(log, []string{"outer", "inner"}) {
+    t.Error("middleware should wrap the handler in order", out, err, log)
+  }
+}
+
+func TestDispatchConcurrent(t *testing.T) {
+  d := &dispatch.Dispatcher{}
+  var wg sync.WaitGroup
+
+  for k := 0; k < 8; k++ {
+    wg.Add(1)
+    go func(k int) {
+      defer wg.Done()
+      pat := map[string]string{"a": fmt.Sprint(k)}
+      d.Add(pat, reply(k))
+      if out, err := d.Act(context.Background(), map[string]interface{}{"a": k}); out != k || err != nil {
+        t.Error("concurrent Act", k, out, err)
+      }
+    }(k)
+  }
+
+  wg.Wait()
+}
@@ -0,0 +1,5 @@
+//go:build !race
+
+package patrun
+
+const raceEnabled = false
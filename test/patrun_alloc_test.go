@@ -0,0 +1,78 @@
+package patrun
+
+import (
+  "github.com/colmharte/patrun-golang/patrun"
+  "testing"
+)
+
+func TestFindAllocs(t *testing.T) {
+  r := patrun.Patrun{}
+
+  r.AddString("a:1", "A")
+  r.AddString("a:1,b:2", "B")
+  r.AddString("c:3", "C")
+  r.AddString("b:1,c:4", "D")
+
+  subject := map[string]string{"a":"0", "b":"0", "c":"3", "z":"9"}
+
+  if r.Find(subject).(string) != "C" {
+    t.Error("a:0,b:0,c:3,z:9 Find should be C", r.Find(subject));
+  }
+  if r.FindString("a:0, b:0, c:3, z:9").(string) != "C" {
+    t.Error("a:0,b:0,c:3,z:9 FindString should be C", r.FindString("a:0, b:0, c:3, z:9"));
+  }
+
+  if raceEnabled {
+    t.Skip("allocation counts are not reliable with the race detector")
+  }
+
+  allocs := testing.AllocsPerRun(100, func() {
+    r.Find(subject)
+  })
+  if allocs != 0 {
+    t.Error("Find should not allocate", allocs);
+  }
+
+  allocs = testing.AllocsPerRun(100, func() {
+    r.FindExact(subject)
+  })
+  if allocs != 0 {
+    t.Error("FindExact should not allocate", allocs);
+  }
+
+  allocs = testing.AllocsPerRun(100, func() {
+    r.FindString("a:0, b:0, c:3, z:9")
+  })
+  if allocs != 0 {
+    t.Error("FindString should not allocate", allocs);
+  }
+}
+
+func TestFindStringNotation(t *testing.T) {
+  r := patrun.Patrun{}
+
+  r.AddString("a:1", "A")
+  r.AddString("a:1,b:2", "B")
+
+  if r.FindString("b:2, a:1").(string) != "B" {
+    t.Error("b:2,a:1 Find should be B", r.FindString("b:2, a:1"));
+  }
+  if r.FindString("a:1,b:3,b:2").(string) != "B" {
+    t.Error("a:1,b:3,b:2 Find should be B, last duplicate wins", r.FindString("a:1,b:3,b:2"));
+  }
+  if r.FindString("a:1,b:2,b:3").(string) != "A" {
+    t.Error("a:1,b:2,b:3 Find should be A, last duplicate wins", r.FindString("a:1,b:2,b:3"));
+  }
+  if r.FindString("a:1,b:2:3,,").(string) != "A" {
+    t.Error("a:1,b:2:3 Find should be A, malformed items are ignored", r.FindString("a:1,b:2:3,,"));
+  }
+  if r.FindExactString("a:1,b") != "A" {
+    t.Error("a:1,b FindExact should be A, malformed items are ignored", r.FindExactString("a:1,b"));
+  }
+
+  p := patrun.Patrun{}
+  p.AddString(" b : 2, a:1,c,d:4:5,a:3 ", "X")
+  if items := p.List(nil, false); len(items) != 1 || formatMatch(items[0].Match) != "a:3 b:2" {
+    t.Error("AddString should add a:3 b:2", items);
+  }
+}
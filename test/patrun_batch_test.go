@@ -0,0 +1,66 @@
+package patrun
+
+import (
+  "github.com/colmharte/patrun-golang/patrun"
+  "testing"
+  "fmt"
+)
+
+func TestFindMany(t *testing.T) {
+  r := patrun.Patrun{}
+
+  r.AddString("a:1", "A")
+  r.AddString("a:1,b:2", "B")
+  r.AddString("c:3", "C")
+  r.AddString("b:1,c:4", "D")
+
+  subjects := []map[string]string{
+    {"a":"1"},
+    {"a":"2"},
+    {"a":"1", "b":"2"},
+    {"a":"1", "b":"1"},
+    {"b":"1", "c":"4"},
+    {"b":"1", "c":"3"},
+    {"c":"3", "a":"0", "b":"0"},
+    {},
+    nil,
+  }
+
+  results := r.FindMany(subjects)
+  if len(results) != len(subjects) {
+    t.Fatal("FindMany should return a result per subject", len(results));
+  }
+
+  for k := range subjects {
+    if results[k] != r.Find(subjects[k]) {
+      t.Error(fmt.Sprintf("FindMany %v should be %v", subjects[k], r.Find(subjects[k])), results[k]);
+    }
+  }
+
+  if len(r.FindMany(nil)) != 0 {
+    t.Error("FindMany of nil should be empty", r.FindMany(nil));
+  }
+}
+
+func TestFindManyParallel(t *testing.T) {
+  r := patrun.Patrun{}
+
+  var subjects []map[string]string
+  for i := 0; i < 100; i++ {
+    r.Add(map[string]string{"x":fmt.Sprintf("%v", i)}, i)
+    r.Add(map[string]string{"x":fmt.Sprintf("%v", i), "y":"1"}, -i)
+
+    subjects = append(subjects, map[string]string{"x":fmt.Sprintf("%v", i)})
+    subjects = append(subjects, map[string]string{"x":fmt.Sprintf("%v", i), "y":"1"})
+  }
+
+  for _, workers := range []int{0, 1, 3, 8, 1000} {
+    results := r.FindManyParallel(subjects, workers)
+
+    for k := range subjects {
+      if results[k] != r.Find(subjects[k]) {
+        t.Error(fmt.Sprintf("FindManyParallel(%v) %v should be %v", workers, subjects[k], r.Find(subjects[k])), results[k]);
+      }
+    }
+  }
+}
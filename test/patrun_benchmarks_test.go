@@ -6,6 +6,7 @@ import (
   "fmt"
   "math/rand"
   "time"
+  "bytes"
 )
 
 func setup() (patrun.Patrun, [100]string) {
@@ -96,3 +97,61 @@ func BenchmarkSimple(b *testing.B) {
   fmt.Println("EXECUTED: ", be.Sub(bs))
 
 }
+
+func BenchmarkFindAllocs(b *testing.B) {
+  r := patrun.Patrun{}
+
+  r.AddString("a:1", "A")
+  r.AddString("a:1,b:2", "B")
+  r.AddString("c:3", "C")
+
+  subject := map[string]string{"a":"1", "b":"2", "c":"3"}
+
+  b.ReportAllocs()
+  for n := 0; n < b.N; n++ {
+    r.Find(subject)
+    r.FindString("a:1,b:2,c:3")
+  }
+}
+
+func BenchmarkLoadJSON(b *testing.B) {
+  r, _ := setup()
+  data, _ := r.ToJSON()
+
+  b.ResetTimer()
+  for n := 0; n < b.N; n++ {
+    patrun.FromJSON(data, nil)
+  }
+}
+
+func BenchmarkReadSnapshot(b *testing.B) {
+  r, _ := setup()
+
+  var data bytes.Buffer
+  r.WriteTo(&data)
+
+  b.ResetTimer()
+  for n := 0; n < b.N; n++ {
+    p := patrun.Patrun{}
+    p.ReadFrom(bytes.NewReader(data.Bytes()))
+  }
+}
+
+func BenchmarkLint(b *testing.B) {
+  r := patrun.Patrun{}
+
+  for i := 0; i < 100; i++ {
+    r.AddString(fmt.Sprintf("role:r%v", i), i)
+    r.AddString(fmt.Sprintf("role:r%v,cmd:c%v", i, i), i)
+    for j := 0; j < 100; j++ {
+      r.AddString(fmt.Sprintf("role:r%v,cmd:c%v,id:%v", i, i, j), j)
+    }
+  }
+  r.AddString("admin:true", "admin")
+
+  b.ReportAllocs()
+  b.ResetTimer()
+  for n := 0; n < b.N; n++ {
+    r.Lint()
+  }
+}
@@ -0,0 +1,74 @@
+package patrun
+
+import (
+  "github.com/colmharte/patrun-golang/patrun"
+  "testing"
+)
+
+func TestClone(t *testing.T) {
+  r := patrun.Patrun{}
+
+  r.AddString("", "R")
+  r.AddString("a:1", "A")
+  r.AddString("a:1,b:2", "B")
+
+  c := r.Clone()
+
+  if c.String() != r.String() {
+    t.Error("Clone should have the same patterns", c.String());
+  }
+
+  c.AddString("a:1,b:3", "B3")
+  c.AddString("a:1", "AA")
+  c.RemoveString("")
+
+  if r.String() != " -> <R>\na:1 -> <A>\na:1, b:2 -> <B>" {
+    t.Error("original should be unchanged by the Clone", r.String());
+  }
+  if c.String() != "a:1 -> <AA>\na:1, b:2 -> <B>\na:1, b:3 -> <B3>" {
+    t.Error("Clone should be a:1 -> <AA>\na:1, b:2 -> <B>\na:1, b:3 -> <B3>", c.String());
+  }
+
+  r.RemoveString("a:1,b:2")
+  if c.FindString("a:1,b:2") != "B" {
+    t.Error("Clone a:1,b:2 Find should be B", c.FindString("a:1,b:2"));
+  }
+
+  e := (&patrun.Patrun{}).Clone()
+  e.AddString("a:1", "A")
+  if e.FindString("a:1") != "A" {
+    t.Error("Clone of empty a:1 Find should be A", e.FindString("a:1"));
+  }
+}
+
+func TestCloneWith(t *testing.T) {
+  r := patrun.Patrun{Custom: new(customHappy)}
+
+  r.AddString("a:1", "A")
+  r.AddString("b:1", []string{"x"})
+
+  c := r.CloneWith(func(data interface{}) interface{} {
+    if items, ok := data.([]string); ok {
+      return append([]string{}, items...)
+    }
+    return data
+  })
+
+  if c.Custom != r.Custom {
+    t.Error("Clone should share the Customiser");
+  }
+
+  r.FindString("b:1,q:9").([]string)[0] = "y"
+
+  if c.FindString("b:1,q:9").([]string)[0] != "x" {
+    t.Error("Clone b:1,q:9 Find should be [x]", c.FindString("b:1,q:9"));
+  }
+  if c.FindString("a:1,q:9") != "A" {
+    t.Error("Clone a:1,q:9 Find should be A", c.FindString("a:1,q:9"));
+  }
+
+  c.AddString("c:1", "C")
+  if c.FindString("c:1,q:9") != "C" {
+    t.Error("Clone should use the Customiser, c:1,q:9 Find should be C", c.FindString("c:1,q:9"));
+  }
+}
@@ -0,0 +1,103 @@
+package patrun
+
+import (
+  "github.com/colmharte/patrun-golang/patrun"
+  "testing"
+  "context"
+  "errors"
+  "fmt"
+)
+
+type requestKey struct {}
+
+//records the request id of every hook call in the log
+type customContext struct {
+  log *[]string
+}
+
+func (c customContext) Add(pm *patrun.Patrun, pat map[string]string, data interface{}) patrun.Modifiers {
+  *c.log = append(*c.log, "add")
+  return contextModifier(c)
+}
+
+func (c customContext) AddContext(ctx context.Context, pm *patrun.Patrun, pat map[string]string, data interface{}) (patrun.Modifiers, error) {
+  *c.log = append(*c.log, fmt.Sprintf("add %v", ctx.Value(requestKey{})))
+  return contextModifier(c), nil
+}
+
+type contextModifier customContext
+
+func (m contextModifier) Find(pm *patrun.Patrun, pat map[string]string, data interface{}) interface{} {
+  *m.log = append(*m.log, "find")
+  return data
+}
+
+func (m contextModifier) Remove(pm *patrun.Patrun, pat map[string]string, data interface{}) bool {
+  *m.log = append(*m.log, "remove")
+  return true
+}
+
+func (m contextModifier) FindContext(ctx context.Context, pm *patrun.Patrun, pat map[string]string, data interface{}) (interface{}, error) {
+  *m.log = append(*m.log, fmt.Sprintf("find %v", ctx.Value(requestKey{})))
+  if data == "slow" {
+    <-ctx.Done()
+    return nil, ctx.Err()
+  }
+  return data, nil
+}
+
+func (m contextModifier) RemoveContext(ctx context.Context, pm *patrun.Patrun, pat map[string]string, data interface{}) (bool, error) {
+  *m.log = append(*m.log, fmt.Sprintf("remove %v", ctx.Value(requestKey{})))
+  return true, nil
+}
+
+func TestContextHooks(t *testing.T) {
+  var log []string
+  pm := &patrun.Patrun{Custom: customContext{&log}}
+
+  ctx := context.WithValue(context.Background(), requestKey{}, "r1")
+
+  pm.AddContext(ctx, map[string]string{"a": "1"}, "A")
+  pm.Add(map[string]string{"a": "2"}, "B")
+
+  if data, err := pm.FindContext(ctx, map[string]string{"a": "1"}); data != "A" || err != nil {
+    t.Error("FindContext", data, err)
+  }
+  if data, err := pm.FindExactContext(ctx, map[string]string{"a": "2", "b": "1"}); !errors.Is(err, patrun.ErrNotFound) {
+    t.Error("FindExactContext should return ErrNotFound", data, err)
+  }
+  pm.Find(map[string]string{"a": "2"})
+  pm.FindE(map[string]string{"a": "2"})
+  pm.RemoveContext(ctx, map[string]string{"a": "1"})
+
+  expected := []string{"add r1", "add", "find r1", "find r1", "find", "find <nil>", "remove r1"}
+  if fmt.Sprint(log) != fmt.Sprint(expected) {
+    t.Errorf("hooks called %q, expected %q", log, expected)
+  }
+}
+
+func TestContextCancel(t *testing.T) {
+  var log []string
+  pm := &patrun.Patrun{Custom: customContext{&log}}
+  pm.AddString("a:1", "slow")
+
+  ctx, cancel := context.WithCancel(context.Background())
+  cancel()
+
+  if _, err := pm.FindContext(ctx, map[string]string{"a": "1"}); err != context.Canceled {
+    t.Error("FindContext should check the context first", err)
+  }
+  if err := pm.AddContext(ctx, map[string]string{"a": "2"}, "B"); err != context.Canceled || pm.FindString("a:2") != nil {
+    t.Error("AddContext should check the context first", err)
+  }
+  if err := pm.RemoveContext(ctx, map[string]string{"a": "1"}); err != context.Canceled || pm.FindString("a:1") == nil {
+    t.Error("RemoveContext should check the context first", err)
+  }
+
+  ctx, cancel = context.WithCancel(context.Background())
+  go cancel()
+
+  if _, err := pm.FindContext(ctx, map[string]string{"a": "1"}); err != context.Canceled {
+    t.Error("modifiers should be able to wait on the context", err)
+  }
+}
@@ -0,0 +1,88 @@
+package patrun
+
+import (
+  "github.com/colmharte/patrun-golang/patrun"
+  "testing"
+  "strings"
+)
+
+func TestDiff(t *testing.T) {
+  a := &patrun.Patrun{}
+  a.AddString("", "R")
+  a.AddString("a:1", "A")
+  a.AddString("a:1,b:2", "B")
+  a.AddString("c:3", "C")
+
+  b := a.Clone()
+
+  if !patrun.Diff(a, b).Empty() {
+    t.Error("Diff of a Clone should be empty", patrun.Diff(a, b));
+  }
+  if patrun.Diff(a, b).String() != "" {
+    t.Error("Diff of a Clone should render as empty", patrun.Diff(a, b).String());
+  }
+
+  b.RemoveString("")
+  b.AddString("a:1", "A2")
+  b.AddString("a:1,b:3", "B3")
+  b.RemoveString("c:3")
+
+  d := patrun.Diff(a, b)
+
+  if convertListToString(d.Added) != "[{map[a:1 b:3] B3}]" {
+    t.Error("Diff Added should be [{map[a:1 b:3] B3}]", convertListToString(d.Added));
+  }
+  if convertListToString(d.Removed) != "[{map[] R} {map[c:3] C}]" {
+    t.Error("Diff Removed should be [{map[] R} {map[c:3] C}]", convertListToString(d.Removed));
+  }
+  if len(d.Changed) != 1 || formatMatch(d.Changed[0].Match) != "a:1" || d.Changed[0].Old != "A" || d.Changed[0].New != "A2" {
+    t.Error("Diff Changed should be a:1 A -> A2", d.Changed);
+  }
+
+  expected := strings.Join([]string{
+    "--- old",
+    "+++ new",
+    "- -> <R>",
+    "-a:1 -> <A>",
+    "+a:1 -> <A2>",
+    "+a:1, b:3 -> <B3>",
+    "-c:3 -> <C>",
+  }, "\n")
+  if d.String() != expected {
+    t.Error("Diff String should be\n" + expected, "\n" + d.String());
+  }
+}
+
+func TestDiffWith(t *testing.T) {
+  f := func(amt float64) float64 { return amt }
+  g := func(amt float64) float64 { return amt * 2 }
+
+  a := &patrun.Patrun{}
+  a.AddString("a:1", "x")
+  a.AddString("b:1", f)
+
+  b := &patrun.Patrun{}
+  b.AddString("a:1", "X")
+  b.AddString("b:1", f)
+
+  if len(patrun.Diff(a, b).Changed) != 1 {
+    t.Error("Diff should find one change, the same func is equal", patrun.Diff(a, b).Changed);
+  }
+
+  b.AddString("b:1", g)
+  if len(patrun.Diff(a, b).Changed) != 2 {
+    t.Error("Diff should find two changes", patrun.Diff(a, b).Changed);
+  }
+
+  d := patrun.DiffWith(a, b, func(x, y interface{}) bool {
+    sx, okx := x.(string)
+    sy, oky := y.(string)
+    return okx && oky && strings.EqualFold(sx, sy)
+  })
+  if len(d.Changed) != 1 || formatMatch(d.Changed[0].Match) != "b:1" {
+    t.Error("DiffWith should only find b:1 changed", d.Changed);
+  }
+  if !strings.Contains(d.String(), "-b:1 -> <function>") {
+    t.Error("Diff String should format functions", d.String());
+  }
+}
@@ -0,0 +1,132 @@
+package patrun
+
+import (
+  "github.com/colmharte/patrun-golang/patrun"
+  "testing"
+  "errors"
+  "reflect"
+)
+
+var errLocked = errors.New("locked")
+
+//rejects patterns with a locked property and fails to find or remove patterns stored as "fail"
+type customChecked struct {}
+
+func (c customChecked) Add(pm *patrun.Patrun, pat map[string]string, data interface{}) patrun.Modifiers {
+  return checkedModifier{}
+}
+
+func (c customChecked) AddE(pm *patrun.Patrun, pat map[string]string, data interface{}) (patrun.Modifiers, error) {
+  if pat["locked"] != "" {
+    return nil, errLocked
+  }
+  return checkedModifier{}, nil
+}
+
+type checkedModifier struct {}
+
+func (m checkedModifier) Find(pm *patrun.Patrun, pat map[string]string, data interface{}) interface{} {
+  return data
+}
+
+func (m checkedModifier) Remove(pm *patrun.Patrun, pat map[string]string, data interface{}) bool {
+  return true
+}
+
+func (m checkedModifier) FindE(pm *patrun.Patrun, pat map[string]string, data interface{}) (interface{}, error) {
+  if data == "fail" {
+    return nil, errLocked
+  }
+  return data, nil
+}
+
+func (m checkedModifier) RemoveE(pm *patrun.Patrun, pat map[string]string, data interface{}) (bool, error) {
+  if data == "fail" {
+    return false, errLocked
+  }
+  return data != "keep", nil
+}
+
+func TestErrorMethods(t *testing.T) {
+  pm := &patrun.Patrun{}
+
+  if err := pm.AddE(map[string]string{"a": "1"}, "A"); err != nil {
+    t.Error("AddE", err)
+  }
+  if err := pm.AddE(map[string]string{"b": "1"}, nil); !errors.Is(err, patrun.ErrInvalidPattern) {
+    t.Error("AddE should reject nil data", err)
+  }
+
+  if data, err := pm.FindE(map[string]string{"a": "1", "c": "3"}); err != nil || data != "A" {
+    t.Error("FindE", data, err)
+  }
+  if _, err := pm.FindExactE(map[string]string{"a": "1", "c": "3"}); !errors.Is(err, patrun.ErrNotFound) || err.Error() != "patrun: pattern not found: a:1, c:3" {
+    t.Error("FindExactE should return ErrNotFound", err)
+  }
+
+  if err := pm.RemoveE(map[string]string{"a": "2"}); !errors.Is(err, patrun.ErrNotFound) {
+    t.Error("RemoveE of a missing pattern should return ErrNotFound", err)
+  }
+  if err := pm.RemoveE(map[string]string{"a": "1"}); err != nil || pm.Find(map[string]string{"a": "1"}) != nil {
+    t.Error("RemoveE should remove the pattern", err)
+  }
+}
+
+func TestErrorSchema(t *testing.T) {
+  pm := &patrun.Patrun{Schema: &patrun.Schema{Keys: map[string]patrun.KeyRule{"a": {}}}}
+
+  err := pm.AddE(map[string]string{"b": "1"}, "B")
+
+  var schemaErr *patrun.SchemaError
+  if !errors.Is(err, patrun.ErrInvalidPattern) || !errors.As(err, &schemaErr) || schemaErr.Key != "b" {
+    t.Error("AddE should return the SchemaError", err)
+  }
+
+  if _, err := pm.FindE(map[string]string{"b": "1"}); !errors.Is(err, patrun.ErrInvalidPattern) {
+    t.Error("FindE should reject invalid subjects", err)
+  }
+}
+
+func TestErrorCustomiser(t *testing.T) {
+  pm := &patrun.Patrun{Custom: customChecked{}}
+
+  if err := pm.AddE(map[string]string{"a": "1", "locked": "y"}, "A"); err != errLocked || len(pm.List(nil, false)) != 0 {
+    t.Error("AddE should return the CustomiserE error without adding", err)
+  }
+
+  pm.AddE(map[string]string{"a": "1"}, "fail")
+  pm.AddE(map[string]string{"a": "2"}, "keep")
+
+  if _, err := pm.FindE(map[string]string{"a": "1"}); err != errLocked {
+    t.Error("FindE should return the ModifiersE error", err)
+  }
+  if err := pm.RemoveE(map[string]string{"a": "1"}); err != errLocked || pm.Find(map[string]string{"a": "1"}) != "fail" {
+    t.Error("RemoveE should return the ModifiersE error without removing", err)
+  }
+  if err := pm.RemoveE(map[string]string{"a": "2"}); err != nil || pm.Find(map[string]string{"a": "2"}) != "keep" {
+    t.Error("RemoveE should let the modifier decline without an error", err)
+  }
+}
+
+func TestParsePattern(t *testing.T) {
+  if pat, err := patrun.ParsePattern(" a:1, b : 2 ,"); err != nil || !reflect.DeepEqual(pat, map[string]string{"a": "1", "b": "2"}) {
+    t.Error("ParsePattern", pat, err)
+  }
+
+  if pat, err := patrun.ParsePattern(""); err != nil || len(pat) != 0 {
+    t.Error("ParsePattern of an empty string is the root pattern", pat, err)
+  }
+
+  var tests = map[string]string{
+    "a:1,b": `patrun: invalid pattern: "b" has no value`,
+    "a:1:2": `patrun: invalid pattern: "a:1:2" has more than one colon`,
+    ":1": `patrun: invalid pattern: ":1" has no key`,
+    "a:1,a:2": `patrun: invalid pattern: key "a" is repeated`,
+  }
+
+  for pat, msg := range tests {
+    if _, err := patrun.ParsePattern(pat); !errors.Is(err, patrun.ErrInvalidPattern) || err.Error() != msg {
+      t.Errorf("ParsePattern(%q) should fail with %q, got %v", pat, msg, err)
+    }
+  }
+}
@@ -0,0 +1,156 @@
+package patrun
+
+import (
+  "github.com/colmharte/patrun-golang/patrun"
+  "testing"
+  "encoding/json"
+  "fmt"
+  "math/rand"
+  "os"
+  "os/exec"
+  "path/filepath"
+  "strings"
+)
+
+//a main package that runs the generated Match over subjects read from stdin
+const generateHarness = `package main
+
+import (
+  "encoding/json"
+  "os"
+)
+
+func main() {
+  var subjects []map[string]string
+  if err := json.NewDecoder(os.Stdin).Decode(&subjects); err != nil {
+    panic(err)
+  }
+
+  var results []interface{}
+  for _, subject := range subjects {
+    if data, ok := Match(subject); ok {
+      results = append(results, data)
+    } else {
+      results = append(results, nil)
+    }
+  }
+
+  json.NewEncoder(os.Stdout).Encode(results)
+}
+`
+
+func randomPattern(rnd *rand.Rand) map[string]string {
+  var pat = map[string]string{}
+
+  for _, key := range []string{"a", "b", "c", "d", "e"} {
+    if rnd.Intn(3) == 0 {
+      pat[key] = fmt.Sprintf("%v", rnd.Intn(3))
+    }
+  }
+
+  return pat
+}
+
+func TestGenerateGo(t *testing.T) {
+  if testing.Short() {
+    t.Skip("compiles generated code")
+  }
+
+  gobin, err := exec.LookPath("go")
+  if err != nil {
+    t.Skip("go command not found")
+  }
+
+  rnd := rand.New(rand.NewSource(1))
+  r := &patrun.Patrun{}
+
+  for i := 0; i < 200; i++ {
+    r.Add(randomPattern(rnd), fmt.Sprintf("r%v \"quoted\"", i))
+  }
+  // leave some empty branches behind, Find still walks through them
+  for i := 0; i < 40; i++ {
+    r.Remove(randomPattern(rnd))
+  }
+
+  var subjects []map[string]string
+  for i := 0; i < 2000; i++ {
+    subject := randomPattern(rnd)
+    if rnd.Intn(4) == 0 {
+      subject["z"] = "1"
+    }
+    subjects = append(subjects, subject)
+  }
+
+  src, err := r.GenerateGo(patrun.GenerateOptions{})
+  if err != nil {
+    t.Fatal("GenerateGo should not fail", err);
+  }
+
+  dir := t.TempDir()
+  os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module harness\n\ngo 1.21\n"), 0666)
+  os.WriteFile(filepath.Join(dir, "main.go"), []byte(generateHarness), 0666)
+  os.WriteFile(filepath.Join(dir, "match.go"), src, 0666)
+
+  input, _ := json.Marshal(subjects)
+
+  cmd := exec.Command(gobin, "run", ".")
+  cmd.Dir = dir
+  cmd.Env = append(os.Environ(), "GO111MODULE=on", "GOFLAGS=")
+  cmd.Stdin = strings.NewReader(string(input))
+
+  output, err := cmd.Output()
+  if err != nil {
+    if exit, ok := err.(*exec.ExitError); ok {
+      t.Fatal("generated code should run", string(exit.Stderr));
+    }
+    t.Fatal("generated code should run", err);
+  }
+
+  var results []interface{}
+  json.Unmarshal(output, &results)
+
+  if len(results) != len(subjects) {
+    t.Fatal("harness should return a result per subject", len(results));
+  }
+
+  for k := range subjects {
+    if results[k] != r.Find(subjects[k]) {
+      t.Error(fmt.Sprintf("generated Match %v should be %v", subjects[k], r.Find(subjects[k])), results[k]);
+    }
+  }
+}
+
+func TestGenerateGoOptions(t *testing.T) {
+  r := &patrun.Patrun{}
+  r.AddString("", 0.0)
+  r.AddString("country:IE", 0.23)
+
+  src, err := r.GenerateGo(patrun.GenerateOptions{Package: "tax", Func: "Rate", Type: "float64"})
+  if err != nil {
+    t.Fatal("GenerateGo should not fail", err);
+  }
+
+  for _, expected := range []string{"package tax\n", "func Rate(subject map[string]string) (float64, bool) {", "func rateStep(", "1: 0.23,"} {
+    if !strings.Contains(string(src), expected) {
+      t.Error("generated code should contain " + expected, string(src));
+    }
+  }
+
+  r.AddString("country:UK", []string{"x"})
+  if _, err := r.GenerateGo(patrun.GenerateOptions{}); err == nil || !strings.Contains(err.Error(), "country:UK") {
+    t.Error("GenerateGo should fail for data it can't write", err);
+  }
+
+  src, err = r.GenerateGo(patrun.GenerateOptions{Type: "interface{}", Literal: func(data interface{}) (string, error) {
+    return fmt.Sprintf("%#v", data), nil
+  }})
+  if err != nil || !strings.Contains(string(src), `[]string{"x"}`) {
+    t.Error("GenerateGo should use the Literal option", err);
+  }
+
+  m := &patrun.Patrun{Custom: new(customTop)}
+  m.AddString("a:1", "A")
+  if _, err := m.GenerateGo(patrun.GenerateOptions{}); err == nil {
+    t.Error("GenerateGo should fail for patterns with modifiers");
+  }
+}
@@ -0,0 +1,101 @@
+package patrun
+
+import (
+  "github.com/colmharte/patrun-golang/patrun"
+  "testing"
+  "strings"
+)
+
+func graphSetup() *patrun.Patrun {
+  r := &patrun.Patrun{}
+
+  r.AddString("a:1", "A")
+  r.AddString("a:1,b:2", "B")
+  r.AddString("c:\"3\"", "C")
+
+  return r
+}
+
+func TestToDOT(t *testing.T) {
+  r := graphSetup()
+
+  expected := strings.Join([]string{
+    "digraph patrun {",
+    "  node [fontname=\"Helvetica\"];",
+    "  n0 [label=\"root\", shape=doublecircle];",
+    "  n1 [label=\"a\", shape=ellipse];",
+    "  n2 [label=\"1\\n<A>\", shape=box, style=filled, fillcolor=\"#ffe9a8\"];",
+    "  n3 [label=\"b\", shape=ellipse];",
+    "  n4 [label=\"2\\n<B>\", shape=box, style=filled, fillcolor=\"#ffe9a8\"];",
+    "  n5 [label=\"c\", shape=ellipse];",
+    "  n6 [label=\"\\\"3\\\"\\n<C>\", shape=box, style=filled, fillcolor=\"#ffe9a8\"];",
+    "  n0 -> n1;",
+    "  n1 -> n2;",
+    "  n2 -> n3;",
+    "  n3 -> n4;",
+    "  n0 -> n5;",
+    "  n5 -> n6;",
+    "}",
+    "",
+  }, "\n")
+
+  if r.ToDOT() != expected {
+    t.Error("ToDOT should be\n" + expected, "\n" + r.ToDOT());
+  }
+
+  dot := r.ToDOTWith(patrun.GraphOptions{Subject: map[string]string{"a":"1", "b":"2", "c":"9"}})
+  if strings.Count(dot, "color=\"#d00000\"") != 9 {
+    t.Error("ToDOTWith should highlight 5 nodes and 4 edges", dot);
+  }
+  if !strings.Contains(dot, "n3 -> n4 [color=\"#d00000\", penwidth=2];") || strings.Contains(dot, "n0 -> n5 [") {
+    t.Error("ToDOTWith should highlight the a:1,b:2 path only", dot);
+  }
+
+  if strings.Contains(r.ToDOTWith(patrun.GraphOptions{Subject: map[string]string{"x":"1"}}), "#d00000") {
+    t.Error("ToDOTWith should not highlight when nothing matches");
+  }
+
+  custom := r.ToDOTWith(patrun.GraphOptions{Format: func(data interface{}) string { return "data" }})
+  if strings.Count(custom, "<data>") != 3 {
+    t.Error("ToDOTWith should use the format function", custom);
+  }
+}
+
+func TestToMermaid(t *testing.T) {
+  r := graphSetup()
+  r.AddString("", "R")
+
+  expected := strings.Join([]string{
+    "flowchart TD",
+    "  n0((\"root<br/>#lt;R#gt;\"))",
+    "  n1([\"a\"])",
+    "  n2[\"1<br/>#lt;A#gt;\"]",
+    "  n3([\"b\"])",
+    "  n4[\"2<br/>#lt;B#gt;\"]",
+    "  n5([\"c\"])",
+    "  n6[\"#quot;3#quot;<br/>#lt;C#gt;\"]",
+    "  n0 --> n1",
+    "  n1 --> n2",
+    "  n2 --> n3",
+    "  n3 --> n4",
+    "  n0 --> n5",
+    "  n5 --> n6",
+    "  classDef data fill:#ffe9a8",
+    "  classDef path stroke:#d00000,stroke-width:2px",
+    "  class n0,n2,n4,n6 data",
+    "",
+  }, "\n")
+
+  if r.ToMermaid() != expected {
+    t.Error("ToMermaid should be\n" + expected, "\n" + r.ToMermaid());
+  }
+
+  m := r.ToMermaidWith(patrun.GraphOptions{Subject: map[string]string{"c":"\"3\""}})
+  if !strings.Contains(m, "  class n0,n5,n6 path\n  linkStyle 4,5 stroke") {
+    t.Error("ToMermaidWith should highlight the c path", m);
+  }
+
+  if (&patrun.Patrun{}).ToMermaid() != "flowchart TD\n  n0((\"root\"))\n  classDef data fill:#ffe9a8\n  classDef path stroke:#d00000,stroke-width:2px\n" {
+    t.Error("empty ToMermaid should only have the root", (&patrun.Patrun{}).ToMermaid());
+  }
+}
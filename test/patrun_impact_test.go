@@ -0,0 +1,176 @@
+package patrun
+
+import (
+  "github.com/colmharte/patrun-golang/patrun"
+  "testing"
+  "reflect"
+  "fmt"
+)
+
+func TestExplain(t *testing.T) {
+  r := patrun.Patrun{}
+
+  r.AddString("a:1", "A")
+  r.AddString("a:1,b:2", "B")
+  r.AddString("c:3", "C")
+  r.AddString("c:3,d:4", "D")
+
+  e := r.ExplainString("a:1,b:2,z:0")
+  if e.Data != "B" || formatMatch(e.Match) != "a:1 b:2" {
+    t.Error("a:1,b:2,z:0 Explain should be B from a:1 b:2", e);
+  }
+
+  e = r.ExplainString("a:1,b:0,c:3")
+  if e.Data != "A" || formatMatch(e.Match) != "a:1" {
+    t.Error("a:1,b:0,c:3 Explain should be A from a:1", e);
+  }
+
+  // backtracks past a:0 and b:0 to find c:3,d:4
+  e = r.ExplainString("a:0,b:0,c:3,d:4")
+  if e.Data != "D" || formatMatch(e.Match) != "c:3 d:4" {
+    t.Error("a:0,b:0,c:3,d:4 Explain should be D from c:3 d:4", e);
+  }
+
+  e = r.ExplainString("x:1")
+  if e.Data != nil || e.Match != nil {
+    t.Error("x:1 Explain should not match", e);
+  }
+
+  e = r.ExplainExact(map[string]string{"a":"1", "z":"0"})
+  if e.Data != nil || e.Match != nil {
+    t.Error("a:1,z:0 ExplainExact should not match", e);
+  }
+
+  r.AddString("", "R")
+  e = r.ExplainString("x:1")
+  if e.Data != "R" || e.Match == nil || len(e.Match) != 0 {
+    t.Error("x:1 Explain should be R from the root", e);
+  }
+
+  m := patrun.Patrun{Custom: new(customTop)}
+  m.AddString("a:1", "A")
+  if m.ExplainString("a:1").Data != "A!" {
+    t.Error("a:1 Explain should apply the modifier", m.ExplainString("a:1"));
+  }
+}
+
+func TestImpactAnalysis(t *testing.T) {
+  old := &patrun.Patrun{}
+  old.AddString("country:IE", "0.23")
+  old.AddString("country:IE,type:food", "0.048")
+  old.AddString("country:UK", "0.20")
+
+  new := old.Clone()
+  new.AddString("country:IE", "0.25")
+  new.RemoveString("country:UK")
+  new.AddString("country:IE,type:reduced", "0.135")
+
+  subjects := []map[string]string{
+    {"country":"IE"},
+    {"country":"IE", "type":"food"},
+    {"country":"IE", "type":"reduced"},
+    {"country":"IE", "type":"other"},
+    {"country":"UK"},
+    {"country":"DE"},
+  }
+
+  report := patrun.ImpactAnalysis(old, new, subjects)
+
+  if report.Subjects != 6 {
+    t.Error("ImpactAnalysis should replay 6 subjects", report.Subjects);
+  }
+  if len(report.Changes) != 4 {
+    t.Error("ImpactAnalysis should find 4 changes", report.Changes);
+  }
+
+  groups := report.ByPattern()
+  if len(groups) != 3 {
+    t.Fatal("ImpactAnalysis should have 3 groups", groups);
+  }
+  if formatMatch(groups[0].Match) != "country:IE" || len(groups[0].Changes) != 2 {
+    t.Error("first group should be country:IE with 2 changes", groups[0]);
+  }
+  if formatMatch(groups[1].Match) != "country:IE type:reduced" || groups[1].Changes[0].Old.Data != "0.23" || groups[1].Changes[0].New.Data != "0.135" {
+    t.Error("second group should be country:IE type:reduced", groups[1]);
+  }
+  if formatMatch(groups[2].Match) != "country:UK" || groups[2].Changes[0].New.Data != nil {
+    t.Error("third group should be the removed country:UK", groups[2]);
+  }
+
+  if len(patrun.ImpactAnalysis(old, old.Clone(), subjects).Changes) != 0 {
+    t.Error("ImpactAnalysis of a Clone should find no changes");
+  }
+}
+
+func TestDistinguishingSubjects(t *testing.T) {
+  old := &patrun.Patrun{}
+  old.AddString("a:1", "A")
+  old.AddString("a:1,b:2", "B")
+  old.AddString("c:3", "C")
+
+  new := old.Clone()
+  new.AddString("a:1,c:3", "AC")
+
+  subjects := patrun.DistinguishingSubjects(old, new)
+
+  if len(subjects) == 0 {
+    t.Fatal("DistinguishingSubjects should find subjects");
+  }
+  for _, subject := range subjects {
+    if old.Find(subject) == new.Find(subject) {
+      t.Error("subject should give different results", subject);
+    }
+  }
+  if len(subjects) != 1 || formatMatch(subjects[0]) != "a:1 c:3" {
+    t.Error("the only subject should be a:1 c:3", subjects);
+  }
+
+  //c:3,d:4 and a:1,c:3,d:4 change the same way so only the shorter is kept
+  old.AddString("c:3,d:4", "D")
+  new.AddString("c:3,d:4", "D2")
+
+  subjects = patrun.DistinguishingSubjects(old, new)
+
+  var found []string
+  for _, subject := range subjects {
+    found = append(found, formatMatch(subject))
+  }
+  if !reflect.DeepEqual(found, []string{"c:3 d:4", "a:1 c:3"}) {
+    t.Error("there should be one subject per change of outcome", found);
+  }
+
+  if len(patrun.DistinguishingSubjects(old, old.Clone())) != 0 {
+    t.Error("DistinguishingSubjects of a Clone should be empty");
+  }
+}
+
+func TestImpactCandidates(t *testing.T) {
+  old := &patrun.Patrun{}
+  old.AddString("e:E", "E")
+
+  new := old.Clone()
+  new.AddString("c:3", "C")
+
+  //c:3 is walked before e:E so subjects with both change
+  var found []string
+  for _, subject := range patrun.DistinguishingSubjects(old, new) {
+    found = append(found, formatMatch(subject))
+  }
+  if !reflect.DeepEqual(found, []string{"c:3", "c:3 e:E"}) {
+    t.Error("a new branch should change subjects that have an earlier branch", found);
+  }
+
+  //only patterns related to the change are combined with it
+  for i := 0; i < 100; i++ {
+    for j := 0; j < 100; j++ {
+      old.Add(map[string]string{fmt.Sprintf("g%v", i): "1", fmt.Sprintf("h%v", j): "1"}, j)
+    }
+  }
+  new = old.Clone()
+  new.AddString("g0:1,h0:1", "changed")
+
+  report := patrun.ImpactAnalysis(old, new, nil)
+  if report.Subjects > 11000 || len(report.Changes) == 0 {
+    t.Error("ImpactAnalysis should try the patterns and a few combinations", report.Subjects, len(report.Changes));
+  }
+}
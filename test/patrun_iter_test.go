@@ -0,0 +1,71 @@
+package patrun
+
+import (
+  "github.com/colmharte/patrun-golang/patrun"
+  "testing"
+  "fmt"
+)
+
+func TestAllOrder(t *testing.T) {
+  r := patrun.Patrun{}
+
+  r.AddString("", "R")
+  r.AddString("c:3", "C")
+  r.AddString("a:1", "A")
+  r.AddString("a:1,b:2", "B")
+  r.AddString("b:1,c:4", "D")
+
+  var items []patrun.Pattern
+  for item := range r.All() {
+    items = append(items, item)
+  }
+
+  if convertListToString(items) != convertListToString(r.List(nil, false)) {
+    t.Error("All should match List", convertListToString(items), convertListToString(r.List(nil, false)));
+  }
+  if convertListToString(items) != "[{map[] R} {map[a:1] A} {map[a:1 b:2] B} {map[b:1 c:4] D} {map[c:3] C}]" {
+    t.Error("All should be [{map[] R} {map[a:1] A} {map[a:1 b:2] B} {map[b:1 c:4] D} {map[c:3] C}]", convertListToString(items));
+  }
+
+  items = nil
+  for item := range r.Query(map[string]string{"a":"1", "b":"*"}, false) {
+    items = append(items, item)
+  }
+  if convertListToString(items) != convertListToString(r.ListString("a:1,b:*", false)) {
+    t.Error("Query a:1,b:* should match List", convertListToString(items));
+  }
+}
+
+func TestQueryEarlyStop(t *testing.T) {
+  r := patrun.Patrun{}
+
+  for i := 0; i < 100; i++ {
+    r.AddString(fmt.Sprintf("a:%03d", i), i)
+    r.AddString(fmt.Sprintf("a:%03d,b:1", i), -i)
+  }
+
+  var seen []interface{}
+  for item := range r.Query(map[string]string{"a":"*"}, false) {
+    seen = append(seen, item.Data)
+    if len(seen) == 3 {
+      break
+    }
+  }
+
+  if fmt.Sprintf("%v", seen) != "[0 0 1]" {
+    t.Error("Query a:* first three should be [0 0 1]", seen);
+  }
+
+  var found = false
+  for range r.Query(map[string]string{"a":"05*", "b":"1"}, true) {
+    found = true
+    break
+  }
+  if !found {
+    t.Error("Query a:05*,b:1 should find a pattern");
+  }
+
+  for range (&patrun.Patrun{}).All() {
+    t.Error("All on an empty matcher should yield nothing");
+  }
+}
@@ -0,0 +1,144 @@
+package patrun
+
+import (
+  "github.com/colmharte/patrun-golang/patrun"
+  "testing"
+  "encoding/json"
+  "fmt"
+)
+
+func TestJSONRoundTrip(t *testing.T) {
+  r := patrun.Patrun{}
+
+  r.AddString("", "R")
+  r.AddString("a:1", "A")
+  r.AddString("a:1,b:2", 2.5)
+  r.AddString("c:3", []interface{}{"x", true})
+
+  b, err := r.ToJSON()
+  if err != nil {
+    t.Fatal("ToJSON should not fail", err);
+  }
+
+  c, err := patrun.FromJSON(b, nil)
+  if err != nil {
+    t.Fatal("FromJSON should not fail", err);
+  }
+
+  if c.String() != r.String() {
+    t.Error("FromJSON should give the same patterns", c.String());
+  }
+  if !patrun.Diff(&r, c).Empty() {
+    t.Error("FromJSON should give the same data", patrun.Diff(&r, c));
+  }
+
+  _, err = patrun.FromJSON([]byte(`[{"Match":{"a":"1"}`), nil)
+  if err == nil {
+    t.Error("FromJSON of bad JSON should fail");
+  }
+
+  _, err = patrun.FromJSON([]byte(`[{"Match":{"a":"1"},"Data":null}]`), nil)
+  if err == nil {
+    t.Error("FromJSON without data should fail");
+  }
+}
+
+type jsonRate struct {
+  Rate float64
+}
+
+func TestJSONEmpty(t *testing.T) {
+  r := patrun.Patrun{}
+
+  b, err := r.ToJSON()
+  if err != nil || string(b) != "null" {
+    t.Error("ToJSON of an empty matcher should be null", string(b), err);
+  }
+
+  c, err := patrun.FromJSON(b, nil)
+  if err != nil || c.String() != "" {
+    t.Error("FromJSON of null should give an empty matcher", c, err);
+  }
+}
+
+func TestJSONCodecs(t *testing.T) {
+  r := patrun.Patrun{}
+  r.AddString("a:1", jsonRate{0.25})
+
+  b, _ := r.ToJSON()
+  if string(b) != `[{"Match":{"a":"1"},"Data":{"Rate":0.25}}]` {
+    t.Error("ToJSON should be [{\"Match\":{\"a\":\"1\"},\"Data\":{\"Rate\":0.25}}]", string(b));
+  }
+
+  c, err := patrun.FromJSON(b, patrun.TypedCodec[jsonRate]{})
+  if err != nil || c.FindString("a:1") != (jsonRate{0.25}) {
+    t.Error("TypedCodec a:1 Find should be {0.25}", c.FindString("a:1"), err);
+  }
+
+  c, err = patrun.FromJSON(b, patrun.RawCodec{})
+  if err != nil || string(c.FindString("a:1").(json.RawMessage)) != `{"Rate":0.25}` {
+    t.Error("RawCodec a:1 Find should be {\"Rate\":0.25}", c.FindString("a:1"), err);
+  }
+
+  rb, _ := c.ToJSONWith(patrun.RawCodec{})
+  if string(rb) != string(b) {
+    t.Error("RawCodec should round trip", string(rb));
+  }
+
+  double := func(amt float64) float64 { return amt * 2 }
+  registry := patrun.RegistryCodec{"double": double}
+
+  f := patrun.Patrun{}
+  f.AddString("op:double", double)
+
+  fb, err := f.ToJSONWith(registry)
+  if err != nil || string(fb) != `[{"Match":{"op":"double"},"Data":"double"}]` {
+    t.Error("RegistryCodec should encode the name", string(fb), err);
+  }
+
+  g, err := patrun.FromJSON(fb, registry)
+  if err != nil || g.FindString("op:double").(func(float64) float64)(2) != 4 {
+    t.Error("RegistryCodec should decode the function", err);
+  }
+
+  _, err = patrun.FromJSON([]byte(`[{"Match":{},"Data":"triple"}]`), registry)
+  if err == nil {
+    t.Error("RegistryCodec should fail for unknown names");
+  }
+
+  _, err = r.ToJSONWith(registry)
+  if err == nil {
+    t.Error("RegistryCodec should fail for unregistered data");
+  }
+
+  rate := func(r float64) func(float64) float64 { return func(amt float64) float64 { return amt * r } }
+  rates := patrun.RegistryCodec{"low": rate(0.1), "high": rate(0.9)}
+
+  h := patrun.Patrun{}
+  h.AddString("band:high", rates["high"])
+  for i := 0; i < 10; i++ {
+    if out, err := h.ToJSONWith(rates); err == nil {
+      t.Fatal("RegistryCodec should fail for data matching more than one name", string(out));
+    }
+  }
+
+  bad := patrun.Patrun{}
+  bad.AddString("a:1", func() {})
+  if _, err := bad.ToJSON(); err == nil {
+    t.Error("ToJSON should return the marshal error");
+  }
+}
+
+func TestLoadJSONCustomiser(t *testing.T) {
+  r := patrun.Patrun{Custom: new(customTop)}
+
+  err := r.LoadJSON([]byte(`[{"Match":{"a":"1"},"Data":"A"}]`), nil)
+  if err != nil || r.FindString("a:1") != "A!" {
+    t.Error("LoadJSON should apply the Customiser", r.FindString("a:1"), err);
+  }
+
+  err = r.LoadJSON([]byte(`[{"Match":{"b":"1"},"Data":{"Rate":1}},{"Match":{"c":"1"},"Data":"x"}]`), patrun.TypedCodec[jsonRate]{})
+  if err == nil || r.FindString("b:1") != nil {
+    t.Error(fmt.Sprintf("failed LoadJSON should not add anything, %v", r.FindString("b:1")), err);
+  }
+}
@@ -0,0 +1,74 @@
+package patrun
+
+import (
+  "github.com/colmharte/patrun-golang/patrun"
+  "testing"
+  "reflect"
+  "context"
+  "errors"
+)
+
+func TestLint(t *testing.T) {
+  pm := &patrun.Patrun{}
+
+  if findings := pm.Lint(); len(findings) != 0 {
+    t.Error("an empty matcher has nothing to lint", findings)
+  }
+
+  pm.AddString("", "R")
+  pm.AddString("a:1", "A")
+  pm.AddString("a:1,b:2", "A")
+  pm.AddString("c:3", "C")
+  pm.AddString("C:3", "C3")
+  pm.Add(map[string]string{"d": ""}, "D")
+  pm.AddString("e:E", "R")
+
+  var got []string
+  for _, finding := range pm.Lint() {
+    got = append(got, finding.String())
+  }
+
+  expected := []string{
+    "error: unreachable: d:: the empty value for d can never be matched",
+    "warning: redundant: a:1, b:2: data <A> is the same as the data of a:1",
+    "warning: near-duplicate: c:3: differs from C:3 only by case or whitespace",
+    "warning: redundant: e:E: data <R> is the same as the data of the root pattern",
+    "info: shadowed: a:1: subjects like C:3, a:1 get the data of C:3 instead",
+    "info: shadowed: a:1, b:2: subjects like C:3, a:1, b:2 get the data of C:3 instead",
+    "info: shadowed: c:3: subjects like C:3, c:3 get the data of C:3 instead",
+    "info: shadowed: c:3: subjects like a:1, c:3 get the data of a:1 instead",
+    "info: shadowed: e:E: subjects like C:3, e:E get the data of C:3 instead",
+    "info: shadowed: e:E: subjects like a:1, e:E get the data of a:1 instead",
+    "info: shadowed: e:E: subjects like c:3, e:E get the data of c:3 instead",
+  }
+
+  if !reflect.DeepEqual(got, expected) {
+    t.Errorf("Lint findings:\n%q\nexpected:\n%q", got, expected)
+  }
+
+  finding := pm.Lint()[0]
+  if finding.Severity != patrun.LintError || finding.Check != patrun.LintUnreachable || finding.Match["d"] != "" {
+    t.Error("unreachable finding", finding)
+  }
+}
+
+func TestLintMiddleware(t *testing.T) {
+  pm := &patrun.Patrun{}
+
+  pm.AddString("a:1", "A")
+  pm.AddString("a:1,b:2", "B")
+
+  //deny subjects without a user, as access control middleware would
+  pm.Use(func(next patrun.FindFunc) patrun.FindFunc {
+    return func(ctx context.Context, p *patrun.Patrun, subject map[string]string, exact bool) (interface{}, error) {
+      if subject["user"] == "" {
+        return nil, errors.New("no user")
+      }
+      return next(ctx, p, subject, exact)
+    }
+  })
+
+  if findings := pm.Lint(); len(findings) != 0 {
+    t.Error("Lint should look at the tree, not the middleware", findings)
+  }
+}
@@ -0,0 +1,89 @@
+package patrun
+
+import (
+  "github.com/colmharte/patrun-golang/patrun"
+  "testing"
+  "errors"
+  "fmt"
+)
+
+func mergeSetup() (*patrun.Patrun, *patrun.Patrun) {
+  base := &patrun.Patrun{}
+  base.AddString("", "R")
+  base.AddString("a:1", "A")
+  base.AddString("a:1,b:2", "B")
+
+  team := &patrun.Patrun{}
+  team.AddString("a:1", "A2")
+  team.AddString("c:3", "C")
+
+  return base, team
+}
+
+func TestMergePolicies(t *testing.T) {
+  base, team := mergeSetup()
+
+  report, err := base.Merge(team, patrun.KeepOurs)
+  if err != nil {
+    t.Error("KeepOurs Merge should not fail", err);
+  }
+  if report.Added != 1 || len(report.Conflicts) != 1 {
+    t.Error("KeepOurs Merge should add 1 and have 1 conflict", report);
+  }
+  if c := report.Conflicts[0]; formatMatch(c.Match) != "a:1" || c.Ours != "A" || c.Theirs != "A2" || c.Result != "A" {
+    t.Error("KeepOurs conflict should be a:1 A A2 A", c);
+  }
+  if rs(*base) != "<R>a:1<A>a:1,b:2<B>c:3<C>" {
+    t.Error("KeepOurs Merge should be <R>a:1<A>a:1,b:2<B>c:3<C>", rs(*base));
+  }
+
+  base, team = mergeSetup()
+  base.Merge(team, patrun.TakeTheirs)
+  if rs(*base) != "<R>a:1<A2>a:1,b:2<B>c:3<C>" {
+    t.Error("TakeTheirs Merge should be <R>a:1<A2>a:1,b:2<B>c:3<C>", rs(*base));
+  }
+
+  base, team = mergeSetup()
+  base.Merge(team, patrun.CombineWith(func(pat map[string]string, ours interface{}, theirs interface{}) interface{} {
+    return fmt.Sprintf("%v+%v", ours, theirs)
+  }))
+  if base.FindString("a:1") != "A+A2" {
+    t.Error("CombineWith a:1 Find should be A+A2", base.FindString("a:1"));
+  }
+  if team.FindString("a:1") != "A2" {
+    t.Error("Merge should not change the other matcher", team.FindString("a:1"));
+  }
+
+  base, team = mergeSetup()
+  report, err = base.Merge(team, patrun.FailOnConflict)
+  if !errors.Is(err, patrun.ErrConflict) {
+    t.Error("FailOnConflict Merge should fail with ErrConflict", err);
+  }
+  if rs(*base) != "<R>a:1<A>a:1,b:2<B>" {
+    t.Error("failed Merge should not change the matcher", rs(*base));
+  }
+
+  _, err = base.Merge(team, nil)
+  if !errors.Is(err, patrun.ErrConflict) {
+    t.Error("nil policy Merge should fail with ErrConflict", err);
+  }
+}
+
+func TestMergeKeepsModifiers(t *testing.T) {
+  upper := &patrun.Patrun{Custom: new(customTop)}
+  upper.AddString("a:1", "x")
+
+  r := &patrun.Patrun{}
+  r.AddString("b:1", "y")
+
+  _, err := r.Merge(upper, nil)
+  if err != nil {
+    t.Error("Merge should not fail", err);
+  }
+  if r.FindString("a:1") != "x!" {
+    t.Error("merged a:1 Find should use its modifier", r.FindString("a:1"));
+  }
+  if r.FindString("b:1") != "y" {
+    t.Error("b:1 Find should be y", r.FindString("b:1"));
+  }
+}
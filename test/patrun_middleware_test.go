@@ -0,0 +1,195 @@
+package patrun
+
+import (
+  "github.com/colmharte/patrun-golang/patrun"
+  "testing"
+  "context"
+  "errors"
+  "fmt"
+  "reflect"
+)
+
+//records the order middleware runs in
+func tracer(name string, log *[]string) patrun.Middleware {
+  return func(next patrun.FindFunc) patrun.FindFunc {
+    return func(ctx context.Context, pm *patrun.Patrun, subject map[string]string, exact bool) (interface{}, error) {
+      *log = append(*log, name + " in")
+      data, err := next(ctx, pm, subject, exact)
+      *log = append(*log, fmt.Sprintf("%v out %v", name, data))
+      return data, err
+    }
+  }
+}
+
+func TestMiddlewareOrder(t *testing.T) {
+  var log []string
+
+  pm := &patrun.Patrun{}
+  pm.AddString("a:1", "A")
+  pm.Use(tracer("first", &log)).Use(tracer("second", &log))
+
+  if pm.FindString("a:1") != "A" {
+    t.Error("FindString should go through the middleware")
+  }
+
+  expected := []string{"first in", "second in", "second out A", "first out A"}
+  if !reflect.DeepEqual(log, expected) {
+    t.Errorf("middleware ran %q, expected %q", log, expected)
+  }
+}
+
+func TestMiddlewareUses(t *testing.T) {
+  pm := &patrun.Patrun{}
+  pm.AddString("a:1", "A").AddString("a:1,b:2", "B").AddString("a:2", "C")
+
+  var calls int
+  var cache = map[string]interface{}{}
+  var denied = errors.New("denied")
+
+  pm.Use(
+    //access control
+    func(next patrun.FindFunc) patrun.FindFunc {
+      return func(ctx context.Context, pm *patrun.Patrun, subject map[string]string, exact bool) (interface{}, error) {
+        if subject["user"] == "guest" {
+          return nil, denied
+        }
+        return next(ctx, pm, subject, exact)
+      }
+    },
+    //result post processing
+    func(next patrun.FindFunc) patrun.FindFunc {
+      return func(ctx context.Context, pm *patrun.Patrun, subject map[string]string, exact bool) (interface{}, error) {
+        data, err := next(ctx, pm, subject, exact)
+        if s, ok := data.(string); ok {
+          return "[" + s + "]", err
+        }
+        return data, err
+      }
+    },
+    //caching, keyed on the subject and exact flag
+    func(next patrun.FindFunc) patrun.FindFunc {
+      return func(ctx context.Context, pm *patrun.Patrun, subject map[string]string, exact bool) (interface{}, error) {
+        key := fmt.Sprint(subject, exact)
+        if data, ok := cache[key]; ok {
+          return data, nil
+        }
+        calls++
+        data, err := next(ctx, pm, subject, exact)
+        cache[key] = data
+        return data, err
+      }
+    },
+    //subject rewriting
+    func(next patrun.FindFunc) patrun.FindFunc {
+      return func(ctx context.Context, pm *patrun.Patrun, subject map[string]string, exact bool) (interface{}, error) {
+        if subject["a"] == "one" {
+          subject = map[string]string{"a": "1", "b": subject["b"]}
+        }
+        return next(ctx, pm, subject, exact)
+      }
+    },
+  )
+
+  if pm.Find(map[string]string{"a": "1"}) != "[A]" || pm.Find(map[string]string{"a": "1"}) != "[A]" || calls != 1 {
+    t.Error("results should be post processed and cached", calls)
+  }
+  if pm.FindString("a:one,b:2") != "[B]" {
+    t.Error("subjects should be rewritten")
+  }
+  if pm.FindExactString("a:1,c:3") != nil || pm.FindExact(map[string]string{"a": "2"}) != "[C]" {
+    t.Error("FindExact should go through the middleware")
+  }
+  if pm.Find(map[string]string{"a": "1", "user": "guest"}) != nil {
+    t.Error("Find should return nil when the middleware fails")
+  }
+  if _, err := pm.FindE(map[string]string{"a": "1", "user": "guest"}); err != denied {
+    t.Error("FindE should return the middleware error", err)
+  }
+  if _, err := pm.FindContext(context.Background(), map[string]string{"a": "3"}); !errors.Is(err, patrun.ErrNotFound) {
+    t.Error("FindContext should return ErrNotFound when the chain finds nothing", err)
+  }
+  if results := pm.FindMany([]map[string]string{{"a": "2"}, {"a": "one", "b": "2"}}); !reflect.DeepEqual(results, []interface{}{"[C]", "[B]"}) {
+    t.Error("FindMany should go through the middleware", results)
+  }
+}
+
+func TestMiddlewareClone(t *testing.T) {
+  var log []string
+
+  pm := &patrun.Patrun{}
+  pm.AddString("a:1", "A")
+  pm.Use(tracer("mw", &log))
+
+  c := pm.Clone()
+  c.AddString("a:1", "B")
+
+  if c.FindString("a:1") != "B" || pm.FindString("a:1") != "A" {
+    t.Error("a clone's middleware should find in the clone")
+  }
+  if len(log) != 4 {
+    t.Error("a clone should keep the middleware", log)
+  }
+
+  //a copy shares the tree's nodes but has its own root
+  cp := *pm
+  cp.Add(map[string]string{}, "R")
+
+  if cp.FindString("b:9") != "R" || pm.FindString("b:9") != nil {
+    t.Error("a copy's middleware should find in the copy")
+  }
+}
+
+func TestMiddlewareExplain(t *testing.T) {
+  pm := &patrun.Patrun{}
+  pm.AddString("a:1", "A").AddString("a:2", "B")
+
+  other := &patrun.Patrun{}
+  other.AddString("x:1", "X")
+
+  pm.Use(func(next patrun.FindFunc) patrun.FindFunc {
+    return func(ctx context.Context, pm *patrun.Patrun, subject map[string]string, exact bool) (interface{}, error) {
+      switch subject["a"] {
+      case "one":
+        subject = map[string]string{"a": "2"}
+      case "cached":
+        return "cached", nil
+      case "other":
+        return other.FindContext(ctx, map[string]string{"x": "1"})
+      }
+      return next(ctx, pm, subject, exact)
+    }
+  })
+
+  if e := pm.ExplainString("a:one"); e.Data != "B" || fmt.Sprint(e.Match) != "map[a:2]" {
+    t.Error("Explain should go through the middleware and give the rewritten match", e)
+  }
+  if e := pm.ExplainString("a:cached"); e.Data != "cached" || e.Match != nil {
+    t.Error("Explain should have no match when the middleware answers", e)
+  }
+  if e := pm.ExplainString("a:other"); e.Data != "X" || e.Match != nil {
+    t.Error("Explain should ignore matches from other matchers", e)
+  }
+  if e := pm.ExplainString("a:3"); e.Data != nil || e.Match != nil {
+    t.Error("a:3 Explain should not match", e)
+  }
+}
+
+func TestMiddlewareKeepsSubject(t *testing.T) {
+  pm := &patrun.Patrun{}
+  pm.AddString("a:1", "A")
+
+  var seen []map[string]string
+  pm.Use(func(next patrun.FindFunc) patrun.FindFunc {
+    return func(ctx context.Context, pm *patrun.Patrun, subject map[string]string, exact bool) (interface{}, error) {
+      seen = append(seen, subject)
+      return next(ctx, pm, subject, exact)
+    }
+  })
+
+  pm.FindString("a:1")
+  pm.FindExactString("a:1,b:2")
+
+  if fmt.Sprint(seen) != "[map[a:1] map[a:1 b:2]]" {
+    t.Error("middleware should be able to keep the subjects of the string variants", seen)
+  }
+}
@@ -0,0 +1,114 @@
+package patrun
+
+import (
+  "github.com/colmharte/patrun-golang/patrun"
+  "testing"
+  "regexp"
+  "errors"
+  "bytes"
+)
+
+func taxSchema() *patrun.Schema {
+  return &patrun.Schema{
+    Keys: map[string]patrun.KeyRule{
+      "country": {Values: []string{"IE", "UK", "DE"}},
+      "type": {},
+      "code": {Pattern: regexp.MustCompile(`^[0-9]+$`)},
+    },
+    Required: []string{"country"},
+    MaxProperties: 2,
+  }
+}
+
+func TestSchemaValidate(t *testing.T) {
+  schema := taxSchema()
+
+  var tests = []struct{
+    pat string
+    key string
+    msg string
+  }{
+    {"country:IE", "", ""},
+    {"country:IE,type:food", "", ""},
+    {"contry:IE", "contry", `patrun: invalid pattern contry:IE: unknown key "contry", did you mean "country"`},
+    {"country:IE,zzz:1", "zzz", `patrun: invalid pattern country:IE, zzz:1: unknown key "zzz"`},
+    {"country:FR", "country", `patrun: invalid pattern country:FR: value "FR" is not allowed for country, expected one of [IE UK DE]`},
+    {"country:IE,code:x1", "code", `patrun: invalid pattern code:x1, country:IE: value "x1" for code does not match ^[0-9]+$`},
+    {"type:food", "country", `patrun: invalid pattern type:food: missing required key "country"`},
+    {"country:IE,type:food,code:1", "", `patrun: invalid pattern code:1, country:IE, type:food: 3 properties, at most 2 are allowed`},
+  }
+
+  for _, test := range tests {
+    pat, _ := patrun.ParsePattern(test.pat)
+    err := schema.Validate(pat)
+
+    if test.msg == "" {
+      if err != nil {
+        t.Errorf("%v should be valid, got %v", test.pat, err)
+      }
+      continue
+    }
+
+    var schemaErr *patrun.SchemaError
+    if !errors.As(err, &schemaErr) || schemaErr.Key != test.key || err.Error() != test.msg {
+      t.Errorf("%v should fail with %q on %q, got %v", test.pat, test.msg, test.key, err)
+    }
+  }
+
+  if (&patrun.Patrun{}).Validate(map[string]string{"any": "thing"}) != nil {
+    t.Error("a matcher without a schema accepts every pattern")
+  }
+}
+
+func TestSchemaAddFind(t *testing.T) {
+  pm := &patrun.Patrun{Schema: taxSchema()}
+
+  pm.AddString("country:IE", "0.23")
+  pm.AddString("contry:IE,type:food", "0.048")
+  pm.AddString("country:IE,type:food", "0.048")
+
+  if len(pm.List(nil, false)) != 2 {
+    t.Error("Add should skip patterns rejected by the schema", pm)
+  }
+
+  if pm.FindString("country:IE,type:food") != "0.048" || pm.Find(map[string]string{"country": "IE"}) != "0.23" {
+    t.Error("valid subjects should match", pm)
+  }
+  if pm.FindString("country:IE,type:food,code:x") != nil || pm.FindString("type:food") != nil || pm.FindExactString("country:FR") != nil {
+    t.Error("Find should reject invalid subjects")
+  }
+  if pm.ExplainString("country:IE,zzz:1").Match != nil {
+    t.Error("Explain should reject invalid subjects")
+  }
+  if results := pm.FindMany([]map[string]string{{"country": "IE"}, {"country": "IE", "zzz": "1"}}); results[0] != "0.23" || results[1] != nil {
+    t.Error("FindMany should reject invalid subjects", results)
+  }
+
+  if pm.Clone().Schema != pm.Schema {
+    t.Error("Clone should keep the schema")
+  }
+}
+
+func TestSchemaLoad(t *testing.T) {
+  var schemaErr *patrun.SchemaError
+
+  pm := &patrun.Patrun{Schema: taxSchema()}
+  err := pm.LoadJSON([]byte(`[{"Match":{"country":"IE"},"Data":"A"},{"Match":{"country":"FR"},"Data":"B"}]`), nil)
+  if !errors.As(err, &schemaErr) || len(pm.List(nil, false)) != 0 {
+    t.Error("LoadJSON should reject invalid patterns without adding any", err, pm)
+  }
+
+  other := &patrun.Patrun{}
+  other.AddString("country:IE", "A").AddString("country:IE,zzz:1", "B")
+
+  if _, err := pm.Merge(other, nil); !errors.As(err, &schemaErr) || len(pm.List(nil, false)) != 0 {
+    t.Error("Merge should reject invalid patterns without adding any", err, pm)
+  }
+
+  var buf bytes.Buffer
+  other.WriteTo(&buf)
+
+  if _, err := pm.ReadFrom(&buf); !errors.As(err, &schemaErr) || len(pm.List(nil, false)) != 0 {
+    t.Error("ReadFrom should reject invalid patterns", err, pm)
+  }
+}
@@ -0,0 +1,176 @@
+package patrun
+
+import (
+  "github.com/colmharte/patrun-golang/patrun"
+  "testing"
+  "bytes"
+  "encoding/binary"
+  "errors"
+  "hash/crc32"
+)
+
+func snapshotSetup() *patrun.Patrun {
+  r := &patrun.Patrun{}
+
+  r.AddString("", "R")
+  r.AddString("a:1", "A")
+  r.AddString("a:1,b:2", "B")
+  r.AddString("c:3", 3.5)
+  r.AddString("c:3,d:4", "D")
+  r.RemoveString("c:3,d:4")
+
+  return r
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+  r := snapshotSetup()
+
+  var b bytes.Buffer
+  n, err := r.WriteTo(&b)
+  if err != nil || n != int64(b.Len()) {
+    t.Fatal("WriteTo should write the whole snapshot", n, err);
+  }
+
+  c := &patrun.Patrun{}
+  c.AddString("x:1", "X")
+
+  m, err := c.ReadFrom(bytes.NewReader(b.Bytes()))
+  if err != nil || m != n {
+    t.Fatal("ReadFrom should read the whole snapshot", m, err);
+  }
+
+  if c.String() != r.String() || !patrun.Diff(r, c).Empty() {
+    t.Error("ReadFrom should replace the patterns", c.String());
+  }
+  if c.FindString("c:3,d:4") != 3.5 || c.FindString("x:1") != "R" {
+    t.Error("snapshot Find should behave the same", c.FindString("c:3,d:4"), c.FindString("x:1"));
+  }
+
+  var e bytes.Buffer
+  (&patrun.Patrun{}).WriteTo(&e)
+  empty := &patrun.Patrun{}
+  if _, err := empty.ReadFrom(&e); err != nil || empty.String() != "" {
+    t.Error("empty snapshot should round trip", err);
+  }
+  empty.AddString("a:1", "A")
+  if empty.FindString("a:1") != "A" {
+    t.Error("matcher loaded from an empty snapshot should be usable", empty.FindString("a:1"));
+  }
+}
+
+func TestSnapshotCustomiser(t *testing.T) {
+  r := snapshotSetup()
+
+  var b bytes.Buffer
+  r.WriteSnapshot(&b, patrun.RawCodec{})
+
+  c := &patrun.Patrun{Custom: new(customTop)}
+  if _, err := c.ReadSnapshot(&b, patrun.TypedCodec[interface{}]{}); err != nil {
+    t.Fatal("ReadSnapshot should not fail", err);
+  }
+  if c.FindString("a:1") != "A!" {
+    t.Error("ReadSnapshot should recreate modifiers with the Customiser", c.FindString("a:1"));
+  }
+
+  b.Reset()
+  c.WriteTo(&b)
+
+  plain := &patrun.Patrun{}
+  plain.AddString("z:9", "Z")
+  if _, err := plain.ReadFrom(bytes.NewReader(b.Bytes())); !errors.Is(err, patrun.ErrSnapshotModifiers) {
+    t.Error("ReadFrom without a Customiser should fail with ErrSnapshotModifiers", err);
+  }
+  if plain.FindString("z:9") != "Z" || plain.FindString("a:1") != nil {
+    t.Error("a failed ReadFrom should leave the matcher unchanged", plain);
+  }
+
+  again := &patrun.Patrun{Custom: new(customTop)}
+  if _, err := again.ReadFrom(&b); err != nil || again.FindString("a:1") != "A!" {
+    t.Error("ReadFrom with a Customiser should recreate modifiers", again.FindString("a:1"), err);
+  }
+}
+
+func TestSnapshotRejected(t *testing.T) {
+  r := snapshotSetup()
+
+  var b bytes.Buffer
+  r.WriteTo(&b)
+  good := b.Bytes()
+
+  corrupt := func(change func(data []byte) []byte) error {
+    data := change(append([]byte{}, good...))
+
+    c := &patrun.Patrun{}
+    c.AddString("x:1", "X")
+
+    _, err := c.ReadFrom(bytes.NewReader(data))
+    if c.FindString("x:1") != "X" {
+      t.Error("rejected snapshot should leave the matcher unchanged");
+    }
+    return err
+  }
+
+  if err := corrupt(func(data []byte) []byte { data[20] ^= 0xff; return data }); !errors.Is(err, patrun.ErrSnapshotChecksum) {
+    t.Error("corrupt body should fail with ErrSnapshotChecksum", err);
+  }
+  if err := corrupt(func(data []byte) []byte { data[0] = 'X'; return data }); !errors.Is(err, patrun.ErrSnapshotFormat) {
+    t.Error("bad magic should fail with ErrSnapshotFormat", err);
+  }
+  if err := corrupt(func(data []byte) []byte { data[5] = 9; return data }); !errors.Is(err, patrun.ErrSnapshotVersion) {
+    t.Error("unknown version should fail with ErrSnapshotVersion", err);
+  }
+  if err := corrupt(func(data []byte) []byte { return data[:len(data) - 10] }); !errors.Is(err, patrun.ErrSnapshotFormat) {
+    t.Error("truncated snapshot should fail with ErrSnapshotFormat", err);
+  }
+  if err := corrupt(func(data []byte) []byte { return data[:3] }); !errors.Is(err, patrun.ErrSnapshotFormat) {
+    t.Error("short header should fail with ErrSnapshotFormat", err);
+  }
+
+  //snapshots with a valid checksum but a broken tree, written by hand
+  malformed := func(body ...byte) []byte {
+    data := append([]byte("PTRN\x00\x01\x00\x00"), binary.BigEndian.AppendUint64(nil, uint64(len(body)))...)
+    data = append(data, body...)
+    return binary.BigEndian.AppendUint32(data, crc32.ChecksumIEEE(body))
+  }
+
+  for _, body := range [][]byte{
+    //data and a modifier on the key node a
+    {4, 'r', 'o', 'o', 't', 0, 1, 1, 'a', 3, 2, '"', 'A', 0},
+    //an empty value node
+    {4, 'r', 'o', 'o', 't', 0, 1, 1, 'a', 0, 1, 0, 1, 2, '"', 'A', 0},
+    //the key node a twice
+    {4, 'r', 'o', 'o', 't', 0, 2, 1, 'a', 0, 0, 1, 'a', 0, 0},
+    //an empty root with children
+    {0, 0, 1, 1, 'a', 0, 0},
+  } {
+    if err := corrupt(func(data []byte) []byte { return malformed(body...) }); !errors.Is(err, patrun.ErrSnapshotFormat) {
+      t.Error("malformed tree should fail with ErrSnapshotFormat", body, err);
+    }
+  }
+
+  bad := &patrun.Patrun{}
+  bad.AddString("a:1", func() {})
+  if _, err := bad.WriteTo(&bytes.Buffer{}); err == nil {
+    t.Error("WriteTo should return data encoding errors");
+  }
+
+  empty := &patrun.Patrun{}
+  empty.Add(map[string]string{"a": ""}, "E")
+  if _, err := empty.WriteTo(&bytes.Buffer{}); err == nil {
+    t.Error("WriteTo should refuse a pattern with an empty value");
+  }
+
+  //an empty key can be found, so it round trips
+  keyless := &patrun.Patrun{}
+  keyless.AddString(":1", "E1")
+  keyless.AddString(":2", "E2")
+
+  var k bytes.Buffer
+  loaded := &patrun.Patrun{}
+  if _, err := keyless.WriteTo(&k); err != nil {
+    t.Error("WriteTo should write a pattern with an empty key", err);
+  }
+  if _, err := loaded.ReadFrom(&k); err != nil || loaded.Find(map[string]string{"": "2"}) != "E2" {
+    t.Error("ReadFrom should read a pattern with an empty key", err);
+  }
+}
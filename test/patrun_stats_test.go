@@ -0,0 +1,51 @@
+package patrun
+
+import (
+  "github.com/colmharte/patrun-golang/patrun"
+  "testing"
+)
+
+func TestStats(t *testing.T) {
+  r := patrun.Patrun{}
+
+  st := r.Stats()
+  if st.Patterns != 0 || st.Nodes != 0 || st.MaxDepth != 0 || st.MemoryBytes != 0 {
+    t.Error("empty Stats should be zero", st);
+  }
+
+  r.AddString("", "R")
+  r.AddString("a:1", "A")
+  r.AddString("a:2", "A2")
+  r.AddString("a:1,b:2", "B")
+  r.AddString("a:1,b:3,c:4", "C")
+
+  st = r.Stats()
+
+  if st.Patterns != 5 || st.Patterns != len(r.List(nil, false)) {
+    t.Error("Stats Patterns should be 5", st.Patterns);
+  }
+  // a, a:1, a:2, a:1/b, b:2, b:3, b:3/c, c:4
+  if st.Nodes != 8 {
+    t.Error("Stats Nodes should be 8", st.Nodes);
+  }
+  if st.MaxDepth != 3 {
+    t.Error("Stats MaxDepth should be 3", st.MaxDepth);
+  }
+  if st.AvgDepth != 1.4 {
+    t.Error("Stats AvgDepth should be 1.4", st.AvgDepth);
+  }
+  if st.FanOut["a"] != 2 || st.FanOut["b"] != 2 || st.FanOut["c"] != 1 {
+    t.Error("Stats FanOut should be map[a:2 b:2 c:1]", st.FanOut);
+  }
+  if st.Values["a"] != 2 || st.Values["b"] != 2 || st.Values["c"] != 1 {
+    t.Error("Stats Values should be map[a:2 b:2 c:1]", st.Values);
+  }
+  if st.MemoryBytes <= 0 {
+    t.Error("Stats MemoryBytes should be positive", st.MemoryBytes);
+  }
+
+  r.RemoveString("a:1,b:3,c:4")
+  if r.Stats().Patterns != 4 {
+    t.Error("Stats Patterns should be 4 after Remove", r.Stats().Patterns);
+  }
+}
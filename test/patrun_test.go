@@ -58,8 +58,8 @@ func TestRoot(t *testing.T) {
       t.Error("pattern should be <R>", rs(r));
     }
 
-    if string(r.ToJSON()[:]) != "[{\"Match\":{},\"Data\":\"R\",\"Modifier\":null}]" {
-      t.Error("JSON pattern should be [{\"Match\":{},\"Data\":\"R\",\"Modifier\":null}]", string(r.ToJSON()[:]));
+    if js(r) != "[{\"Match\":{},\"Data\":\"R\"}]" {
+      t.Error("JSON pattern should be [{\"Match\":{},\"Data\":\"R\"}]", js(r));
     }
 
     if r.Find(map[string]string{}) != "R" {
@@ -83,8 +83,8 @@ func TestRoot(t *testing.T) {
       t.Error("pattern should be <R>a:1<r1>", rs(r));
     }
 
-    if string(r.ToJSON()[:]) != "[{\"Match\":{},\"Data\":\"R\",\"Modifier\":null},{\"Match\":{\"a\":\"1\"},\"Data\":\"r1\",\"Modifier\":null}]" {
-      t.Error("JSON pattern should be [{\"Match\":{},\"Data\":\"R\",\"Modifier\":null},{\"Match\":{\"a\":\"1\"},\"Data\":\"r1\",\"Modifier\":null}]", string(r.ToJSON()[:]));
+    if js(r) != "[{\"Match\":{},\"Data\":\"R\"},{\"Match\":{\"a\":\"1\"},\"Data\":\"r1\"}]" {
+      t.Error("JSON pattern should be [{\"Match\":{},\"Data\":\"R\"},{\"Match\":{\"a\":\"1\"},\"Data\":\"r1\"}]", js(r));
     }
 
 }
@@ -104,8 +104,8 @@ func TestAdd(t *testing.T) {
     t.Error("pattern should be a:1<r1>", pat);
   }
 
-  if string(r.ToJSON()[:]) != "[{\"Match\":{\"a\":\"1\"},\"Data\":\"r1\",\"Modifier\":null}]" {
-    t.Error("JSON pattern should be [{\"Match\":{\"a\":\"1\"},\"Data\":\"r1\",\"Modifier\":null}]", string(r.ToJSON()[:]));
+  if js(r) != "[{\"Match\":{\"a\":\"1\"},\"Data\":\"r1\"}]" {
+    t.Error("JSON pattern should be [{\"Match\":{\"a\":\"1\"},\"Data\":\"r1\"}]", js(r));
   }
 
   r = patrun.Patrun{}
@@ -150,8 +150,8 @@ func TestAdd(t *testing.T) {
   if pat != "a:1,b:3<r2>a:1,c:2<r1>" {
     t.Error("pattern should be a:1,b:3<r2>a:1,c:2<r1>", pat);
   }
-  if string(r.ToJSON()[:]) != "[{\"Match\":{\"a\":\"1\",\"b\":\"3\"},\"Data\":\"r2\",\"Modifier\":null},{\"Match\":{\"a\":\"1\",\"c\":\"2\"},\"Data\":\"r1\",\"Modifier\":null}]" {
-    t.Error("JSON pattern should be [{\"Match\":{\"a\":\"1\",\"b\":\"3\"},\"Data\":\"r2\",\"Modifier\":null},{\"Match\":{\"a\":\"1\",\"c\":\"2\"},\"Data\":\"r1\",\"Modifier\":null}]", string(r.ToJSON()[:]));
+  if js(r) != "[{\"Match\":{\"a\":\"1\",\"b\":\"3\"},\"Data\":\"r2\"},{\"Match\":{\"a\":\"1\",\"c\":\"2\"},\"Data\":\"r1\"}]" {
+    t.Error("JSON pattern should be [{\"Match\":{\"a\":\"1\",\"b\":\"3\"},\"Data\":\"r2\"},{\"Match\":{\"a\":\"1\",\"c\":\"2\"},\"Data\":\"r1\"}]", js(r));
   }
 }
 
@@ -266,6 +266,27 @@ func TestRemove(t *testing.T) {
   }
 }
 
+func TestEmptyKey(t *testing.T) {
+
+  r := patrun.Patrun{}
+
+  r.AddString(":1", "E1")
+  r.AddString(":2", "E2")
+  r.AddString(":1,b:2", "B")
+
+  if r.Find(map[string]string{"": "1"}) != "E1" || r.Find(map[string]string{"": "2"}) != "E2" {
+    t.Error("patterns with an empty key should each be found", r.Find(map[string]string{"": "1"}), r.Find(map[string]string{"": "2"}));
+  }
+  if r.Find(map[string]string{"": "1", "b": "2"}) != "B" {
+    t.Error(":1,b:2 Find should be B", r.Find(map[string]string{"": "1", "b": "2"}));
+  }
+
+  r.Remove(map[string]string{"": "1"})
+  if r.FindExact(map[string]string{"": "1"}) != nil || r.Find(map[string]string{"": "2"}) != "E2" || len(r.List(nil, false)) != 2 {
+    t.Error("Remove of :1 should leave :2 and :1,b:2", r.List(nil, false));
+  }
+}
+
 func TestRemoveIntermediate(t *testing.T) {
 
   r := patrun.Patrun{}
@@ -319,6 +340,26 @@ func TestExact(t *testing.T) {
 
 }
 
+func TestLookup(t *testing.T) {
+  r := patrun.Patrun{}
+
+  r.AddString("a:1", "X" )
+  r.AddString("a:1,b:2,c:3", "Y" )
+
+  if item, found := r.Lookup(map[string]string{"a": "1"}); !found || item.Data.(string) != "X" {
+    t.Error("a:1 Lookup should be X", item, found);
+  }
+  if item, found := r.Lookup(map[string]string{"c": "3", "a": "1", "b": "2"}); !found || item.Data.(string) != "Y" {
+    t.Error("a:1,b:2,c:3 Lookup should be Y", item, found);
+  }
+  if item, found := r.Lookup(map[string]string{"a": "1", "b": "2"}); found {
+    t.Error("a:1,b:2 Lookup should not be found", item);
+  }
+  if _, found := r.Lookup(map[string]string{"a": "2"}); found {
+    t.Error("a:2 Lookup should not be found");
+  }
+}
+
 func TestAll(t *testing.T) {
   r := patrun.Patrun{}
 
@@ -948,6 +989,15 @@ func formatMatch(items map[string]string) string {
 }
 
 
+func js(x patrun.Patrun) string {
+  b, err := x.ToJSON()
+  if err != nil {
+    return err.Error()
+  }
+
+  return string(b)
+}
+
 func rs(x patrun.Patrun) string {
   value := x.String()
 
@@ -0,0 +1,6 @@
+//go:build race
+
+package patrun
+
+//sync.Pool randomly drops items under the race detector so allocation counts aren't meaningful
+const raceEnabled = true
@@ -0,0 +1,187 @@
+package patrun
+
+import (
+  "github.com/colmharte/patrun-golang/patrun"
+  "github.com/colmharte/patrun-golang/patrun/rules"
+  "testing"
+  "errors"
+  "strconv"
+  "strings"
+)
+
+const salesTaxRules = `# sales tax rules
+version: 1
+rules:
+  - match: {}
+    data: "0.0"
+  - match:
+      country: IE   # Ireland
+    data: 0.23
+    meta:
+      owner: tax-team
+  - match: {country: IE, type: food}
+    data: "0.048"
+  - match: {country: IE}
+    data: 0.25
+    priority: 10
+  - match:
+      "city": 'Montgomery, AL'
+      country: US
+    data: "it's 10%: # not a comment"
+`
+
+func TestRulesParse(t *testing.T) {
+  rs, err := rules.Parse("tax.yaml", []byte(salesTaxRules))
+  if err != nil {
+    t.Fatal("Parse should not fail", err);
+  }
+
+  if len(rs.Rules) != 5 {
+    t.Fatal("Parse should find 5 rules", rs.Rules);
+  }
+  if rs.Rules[1].Line != 6 || rs.Rules[1].Data != "0.23" || rs.Rules[1].Meta["owner"] != "tax-team" {
+    t.Error("rule 2 should be on line 6 with data 0.23 and an owner", rs.Rules[1]);
+  }
+  if rs.Rules[3].Priority != 10 {
+    t.Error("rule 4 should have priority 10", rs.Rules[3]);
+  }
+  if rs.Rules[4].Match["city"] != "Montgomery, AL" || rs.Rules[4].Data != "it's 10%: # not a comment" {
+    t.Error("rule 5 should unquote values", rs.Rules[4]);
+  }
+
+  if len(rs.Effective()) != 4 {
+    t.Error("Effective should drop the lower priority duplicate", rs.Effective());
+  }
+
+  pm, err := rs.Build(func(rule rules.Rule) (interface{}, error) {
+    if rule.Match["country"] == "US" {
+      return rule.Data, nil
+    }
+    return strconv.ParseFloat(rule.Data, 64)
+  })
+  if err != nil {
+    t.Fatal("Build should not fail", err);
+  }
+
+  if pm.FindString("country:IE") != 0.25 {
+    t.Error("country:IE Find should be the priority 10 rule", pm.FindString("country:IE"));
+  }
+  if pm.FindString("country:IE,type:food") != 0.048 {
+    t.Error("country:IE,type:food Find should be 0.048", pm.FindString("country:IE,type:food"));
+  }
+  if pm.FindString("country:DE") != 0.0 {
+    t.Error("country:DE Find should be 0.0", pm.FindString("country:DE"));
+  }
+
+  _, err = rs.Build(func(rule rules.Rule) (interface{}, error) {
+    return strconv.ParseFloat(rule.Data, 64)
+  })
+  if err == nil || !strings.HasPrefix(err.Error(), "tax.yaml:16: ") {
+    t.Error("Build should report the line of the rule that failed to decode", err);
+  }
+
+  strict := &patrun.Patrun{Schema: &patrun.Schema{Keys: map[string]patrun.KeyRule{"country": {}, "type": {}}}}
+  err = rs.AddTo(strict, nil)
+
+  var ruleErr *rules.Error
+  if !errors.As(err, &ruleErr) || ruleErr.Line != 16 || !strings.Contains(err.Error(), "city") {
+    t.Error("AddTo should report the line of the rule the Schema rejects", err);
+  }
+  if strict.String() != "" {
+    t.Error("AddTo should add nothing when a rule is rejected", strict.String());
+  }
+
+  partial := &patrun.Patrun{}
+  err = rs.AddTo(partial, func(rule rules.Rule) (interface{}, error) {
+    if rule.Line == 16 {
+      return nil, nil
+    }
+    return rule.Data, nil
+  })
+  if err == nil || err.Error() != "tax.yaml:16: no data" {
+    t.Error("AddTo should report the line of the rule decoded to nil", err);
+  }
+  if partial.String() != "" {
+    t.Error("AddTo should add nothing when a rule has no data", partial.String());
+  }
+}
+
+func TestRulesErrors(t *testing.T) {
+  var tests = []struct{
+    src string
+    err string
+  }{
+    {"rules:\n  - match: {a: 1}\n", "f.yaml:2: rule is missing data"},
+    {"rules:\n  - match: {a: 1}\n    data: x\n    colour: red\n", "f.yaml:4: unknown rule field \"colour\""},
+    {"version: 2\nrules: []\n", "f.yaml:1: unsupported version, expected 1"},
+    {"version: 1\n", "f.yaml:1: missing rules"},
+    {"rules:\n  - match:\n      a: 1\n    data: x\n    priority: high\n", "f.yaml:5: priority must be an integer"},
+    {"rules:\n  - match: {a: 1}\n    data: x\n  - match: {a: 1}\n    data: y\n", "f.yaml:4: duplicate match {a:1} with the same priority as line 2"},
+    {"rules:\n  - match:\n      a: 1\n      a: 2\n    data: x\n", "f.yaml:4: duplicate key \"a\""},
+    {"rules:\n  - match:\n      a: 1\n     b: 2\n", "f.yaml:4: unexpected indentation"},
+    {"rules:\n  - match: [a]\n    data: x\n", "f.yaml:2: unsupported value \"[a]\", use quotes"},
+    {"rules:\n  - match:\n\t  a: 1\n", "f.yaml:3: tabs can't be used for indentation"},
+    {"rules:\n  - match: x\n    data: y\n  - match: {a: 1}\n", "f.yaml:2: match must be a map\nf.yaml:4: rule is missing data"},
+    {"rules:\n  - match: x\n    data: \"y\n", "f.yaml:3: unterminated quoted value"},
+  }
+
+  for _, test := range tests {
+    _, err := rules.Parse("f.yaml", []byte(test.src))
+
+    if err == nil || err.Error() != test.err {
+      t.Error("Parse error should be " + test.err, err);
+    }
+  }
+
+  _, err := rules.Parse("f.yaml", []byte("rules:\n  - data: x\n"))
+  var list rules.ErrorList
+  if !errors.As(err, &list) || list[0].Line != 2 {
+    t.Error("schema errors should be an ErrorList", err);
+  }
+}
+
+func TestRulesRoundTrip(t *testing.T) {
+  pm := &patrun.Patrun{}
+  pm.AddString("", "default")
+  pm.AddString("a:1", "A")
+  pm.AddString("a:1,b:x y", "has: colon, comma #hash")
+  pm.Add(map[string]string{"c:d":"-1", "e":" x"}, "'quoted'")
+
+  rs, err := rules.FromPatrun(pm, nil)
+  if err != nil {
+    t.Fatal("FromPatrun should not fail", err);
+  }
+
+  var b strings.Builder
+  if err := rs.Encode(&b); err != nil {
+    t.Fatal("Encode should not fail", err);
+  }
+
+  back, err := rules.Parse("out.yaml", []byte(b.String()))
+  if err != nil {
+    t.Fatal("encoded rules should parse", err, b.String());
+  }
+
+  pm2, _ := back.Build(nil)
+  if !patrun.Diff(pm, pm2).Empty() {
+    t.Error("rules should round trip", patrun.Diff(pm, pm2), b.String());
+  }
+
+  if string(back.Marshal()) != b.String() {
+    t.Error("Marshal should be stable", string(back.Marshal()));
+  }
+
+  number := &patrun.Patrun{}
+  number.AddString("a:1", 1)
+  if _, err := rules.FromPatrun(number, nil); err == nil {
+    t.Error("FromPatrun should fail for data that isn't a string");
+  }
+
+  empty, _ := rules.FromPatrun(&patrun.Patrun{}, nil)
+  if string(empty.Marshal()) != "version: 1\nrules: []\n" {
+    t.Error("empty rules should be encoded as an empty list", string(empty.Marshal()));
+  }
+  if _, err := rules.Parse("e.yaml", empty.Marshal()); err != nil {
+    t.Error("empty rules should parse", err);
+  }
+}